@@ -0,0 +1,652 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/hasher"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+	"github.com/cyrildever/crumbl-exe/utils"
+)
+
+// The client functions below are safe to embed in any application: they never print to stdout nor exit the process,
+// every diagnostic message being sent to the optional writer passed in the options.
+
+//--- TYPES
+
+// Options holds the stakeholders' keys and the data to use when crumbling or uncrumbling.
+// The keys follow the format of the executable flags, ie. a comma-separated list of colon-separated encryption algorithm prefix
+// and filepath to the public key for OwnerKeys and SignerKeys, and a filepath to the private key for OwnerSecret and SignerSecret.
+// They may rather be described in a keyring file (see Keyring), the invalid keys then failing instead of being reported as warnings.
+type Options struct {
+	OwnerKeys        string
+	OwnerSecret      string
+	SignerKeys       string
+	SignerSecret     string
+	Keyring          string    // Optional: the filepath to a keyring replacing OwnerKeys, OwnerSecret, SignerKeys and SignerSecret
+	Keys             *Keys     // Optional: the keys read beforehand, replacing all the other keys of the options (see LoadKeys)
+	VerificationHash string    // Optional: only checked against the single crumbl or source passed
+	Sources          []string  // The data to crumbl
+	Crumbls          []string  // The crumbls to uncrumbl
+	PartialUncrumbs  []string  // The partial uncrumbs collected for any of the crumbls to uncrumbl
+	Redundancy       int       // Optional: the number of trustees signing each slice when crumbling
+	Threshold        int       // Optional: the number of trustees needed to recover the data when crumbling with their slice shared
+	HashEngine       string    // Optional: the hash engine to use when crumbling, defaults to crypto.DEFAULT_HASH_ENGINE
+	EmitterKeys      string    // Optional: the public keys of the authorized emitters, one of whom must have signed each crumbl to uncrumble, or of the single emitter along with EmitterSecret
+	EmitterSecret    string    // Optional: the filepath to the private key of the emitter signing the crumbls to create
+	NewOwnerKeys     string    // Optional: the public keys of the owners of the rekeyed crumbls, defaults to OwnerKeys, or of the owners to add to the crumbls
+	NewSignerKeys    string    // Optional: the public keys of the trusted signers of the rekeyed crumbls, defaults to SignerKeys
+	ObfuscationKeys  string    // Optional: a comma-separated list of filepaths to obfuscation keys, the first one obfuscating the crumbls to create, all of them besides the default one being accepted when uncrumbling
+	Workers          int       // Defaults to the number of CPUs
+	Diagnostics      io.Writer // Optional destination of warnings
+}
+
+// Format is the output format of an inspection (text or JSON) or of a creation (text or binary)
+type Format string
+
+const (
+	TEXT_FORMAT   Format = "text"
+	JSON_FORMAT   Format = "json"
+	BINARY_FORMAT Format = "binary"
+)
+
+// Result holds the outcome for the item at Index in the passed sources or crumbls, ie. either the resulting value or an error.
+// When uncrumbling, the value is either the original source or partial uncrumbs.
+type Result struct {
+	Index int
+	Value string
+	Err   error
+}
+
+//--- FUNCTIONS
+
+// Crumble creates the crumbl of each source passed in the options.
+// It only returns an error if the options are invalid or the context is done; the failure of a source is held in its result.
+func Crumble(ctx context.Context, opts Options) (results []Result, err error) {
+	if len(opts.Sources) == 0 {
+		err = errors.New("no data to use")
+		return
+	}
+	if len(opts.Sources) != 1 {
+		opts.VerificationHash = ""
+	}
+	return collectStream(ctx, opts.Sources, func(sources <-chan string) (<-chan Result, error) {
+		return CrumbleStream(ctx, opts, sources)
+	})
+}
+
+// CrumbleStream creates the crumbl of each source as it arrives on the passed channel, the sources in the options being ignored,
+// and sends the results in the same order, the index of a result being the position of its source in the stream.
+// The returned channel is closed once the passed channel is closed or the context is done.
+// It only returns an error if the options are invalid, eg. an ErrInvalidOptions for an unknown hash engine or an invalid threshold
+// or redundancy; the failure of a source is held in its result.
+// If set, the verification hash in the options is checked against every source.
+func CrumbleStream(ctx context.Context, opts Options, sources <-chan string) (<-chan Result, error) {
+	log := logger{opts.Diagnostics}
+	hashEngine := opts.HashEngine
+	if hashEngine == "" {
+		hashEngine = crypto.DEFAULT_HASH_ENGINE
+	}
+	keys, err := buildKeySet(opts, log)
+	if err != nil {
+		return nil, err
+	}
+	target, err := buildTarget(opts, keys.owners, keys.signers, log)
+	if err != nil {
+		return nil, err
+	}
+	target.HashEngine = hashEngine
+	if err = target.Check(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOptions, err)
+	}
+
+	crumbler := core.BatchCrumbler{
+		HashEngine:     hashEngine,
+		Owners:         target.Owners,
+		Trustees:       target.Trustees,
+		Redundancy:     target.Redundancy,
+		Threshold:      target.Threshold,
+		Emitter:        target.Emitter,
+		ObfuscationKey: target.ObfuscationKey,
+		Workers:        opts.Workers,
+	}
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for source := range forward(ctx, sources) {
+			if opts.VerificationHash != "" {
+				hash, e := crypto.Hash([]byte(source), hashEngine)
+				if e == nil && utils.ToHex(hash) != opts.VerificationHash {
+					log.warning("verification hash is not coherent with data source")
+				}
+			}
+			select {
+			case in <- source:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return toResults(crumbler.Stream(in)), nil
+}
+
+// Uncrumble deciphers each crumbl passed in the options, either fully when the owner's keys are passed along with
+// all the partial uncrumbs needed, or partially when the keys are those of a trusted signer.
+// The partial uncrumbs are dispatched to the crumbls according to their verification hash.
+// It only returns an error if the options are invalid or the context is done; the failure of a crumbl is held in its result.
+func Uncrumble(ctx context.Context, opts Options) (results []Result, err error) {
+	if len(opts.Crumbls) == 0 {
+		err = errors.New("no data to use")
+		return
+	}
+	if opts.VerificationHash == "" && len(opts.Crumbls) == 1 {
+		logger{opts.Diagnostics}.warning("verification hash is missing")
+	}
+	if len(opts.Crumbls) != 1 {
+		opts.VerificationHash = ""
+	}
+	return collectStream(ctx, opts.Crumbls, func(items <-chan string) (<-chan Result, error) {
+		return UncrumbleStream(ctx, opts, items)
+	})
+}
+
+// UncrumbleStream deciphers each item as it arrives on the passed channel, the crumbls in the options being ignored,
+// and sends the results in the same order, the index of a result being the position of its item in the stream.
+// Each item is a crumbl, optionally followed by its own partial uncrumbs separated by spaces as in a batch file,
+// the partial uncrumbs in the options being dispatched to every crumbl according to their verification hash.
+// The returned channel is closed once the passed channel is closed or the context is done.
+// It only returns an error if the options are invalid; the failure of an item is held in its result.
+// If set, the verification hash in the options is expected from every crumbl.
+func UncrumbleStream(ctx context.Context, opts Options, items <-chan string) (<-chan Result, error) {
+	in, err := uncrumbls(ctx, opts, items, false)
+	if err != nil {
+		return nil, err
+	}
+	uncrumbler := core.BatchUncrumbler{
+		Workers: opts.Workers,
+	}
+	return toResults(uncrumbler.Stream(in)), nil
+}
+
+// Rekey crumbles again the source of each crumbl passed in the options for new stakeholders, the source never leaving the memory.
+// The owner's keys must be passed along with all the partial uncrumbs needed, the new crumbls being created for the owners and
+// trusted signers of NewOwnerKeys and NewSignerKeys, which default to the current ones, ie. those of OwnerKeys and SignerKeys or of the keyring.
+// It only returns an error if the options are invalid or the context is done; the failure of a crumbl is held in its result.
+func Rekey(ctx context.Context, opts Options) (results []Result, err error) {
+	if len(opts.Crumbls) == 0 {
+		err = errors.New("no data to use")
+		return
+	}
+	if len(opts.Crumbls) != 1 {
+		opts.VerificationHash = ""
+	}
+	return collectStream(ctx, opts.Crumbls, func(items <-chan string) (<-chan Result, error) {
+		return RekeyStream(ctx, opts, items)
+	})
+}
+
+// RekeyStream rekeys each item as it arrives on the passed channel, the crumbls in the options being ignored,
+// and sends the new crumbls in the same order, the index of a result being the position of its item in the stream.
+// Each item is a crumbl, optionally followed by its own partial uncrumbs separated by spaces as in a batch file.
+// The returned channel is closed once the passed channel is closed or the context is done.
+// It only returns an error if the options are invalid; the failure of an item is held in its result.
+func RekeyStream(ctx context.Context, opts Options, items <-chan string) (<-chan Result, error) {
+	log := logger{opts.Diagnostics}
+	keys, err := buildKeySet(opts, log)
+	if err != nil {
+		return nil, err
+	}
+	owners, signers := keys.owners, keys.signers
+	if opts.NewOwnerKeys != "" {
+		if owners, err = readPublicKeys(opts.NewOwnerKeys, log); err != nil {
+			return nil, err
+		}
+	}
+	if opts.NewSignerKeys != "" {
+		if signers, err = readPublicKeys(opts.NewSignerKeys, log); err != nil {
+			return nil, err
+		}
+	}
+	target, err := buildTarget(opts, owners, signers, log)
+	if err != nil {
+		return nil, err
+	}
+	in, err := uncrumbls(ctx, opts, items, true)
+	if err != nil {
+		return nil, err
+	}
+	rekeyer := core.BatchRekeyer{
+		Target:  target,
+		Workers: opts.Workers,
+	}
+	return toResults(rekeyer.Stream(in)), nil
+}
+
+// AddOwner adds the owners of NewOwnerKeys to each crumbl passed in the options without involving its trustees,
+// the owner's keys being those of an existing owner of the crumbls.
+// It only returns an error if the options are invalid or the context is done; the failure of a crumbl is held in its result.
+func AddOwner(ctx context.Context, opts Options) (results []Result, err error) {
+	if len(opts.Crumbls) == 0 {
+		err = errors.New("no data to use")
+		return
+	}
+	return collectStream(ctx, opts.Crumbls, func(items <-chan string) (<-chan Result, error) {
+		return AddOwnerStream(ctx, opts, items)
+	})
+}
+
+// AddOwnerStream adds the owners of NewOwnerKeys to each crumbl as it arrives on the passed channel, the crumbls in the options
+// being ignored, and sends the new crumbls in the same order, any data following a crumbl on the same item being ignored.
+// The returned channel is closed once the passed channel is closed or the context is done.
+// It only returns an error if the options are invalid; the failure of an item is held in its result.
+func AddOwnerStream(ctx context.Context, opts Options, items <-chan string) (<-chan Result, error) {
+	log := logger{opts.Diagnostics}
+	keys, err := buildKeySet(opts, log)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys.owners) == 0 {
+		return nil, errors.New("missing public key for the data owner")
+	}
+	newOwnerKeys, err := readPublicKeys(opts.NewOwnerKeys, log)
+	if err != nil {
+		return nil, err
+	}
+	newOwners := buildSigners(newOwnerKeys, log)
+	if len(newOwners) == 0 {
+		return nil, errors.New("missing public keys for the new owners")
+	}
+	owner, isOwner, err := buildUser(keys.ownerOnly(), log)
+	if err != nil {
+		return nil, err
+	}
+	if !isOwner {
+		return nil, errors.New("invalid keys: the data owner's keys are expected")
+	}
+	emitter, err := buildEmitter(opts, log)
+	if err != nil {
+		return nil, err
+	}
+	emitters, err := buildEmitters(opts, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return processStream(ctx, items, func(crumbled string) (string, error) {
+		delegation := core.Delegation{
+			Crumbled:  crumbled,
+			Owner:     owner,
+			NewOwners: newOwners,
+			Emitter:   emitter,
+			Emitters:  emitters,
+		}
+		return delegation.Process()
+	}), nil
+}
+
+// RevokeOwner removes the owners of OwnerKeys, or of the keyring, from each crumbl passed in the options, no private key being needed
+// but the emitter's to sign a signed crumbl again.
+// It only returns an error if the options are invalid or the context is done; the failure of a crumbl is held in its result.
+func RevokeOwner(ctx context.Context, opts Options) (results []Result, err error) {
+	if len(opts.Crumbls) == 0 {
+		err = errors.New("no data to use")
+		return
+	}
+	return collectStream(ctx, opts.Crumbls, func(items <-chan string) (<-chan Result, error) {
+		return RevokeOwnerStream(ctx, opts, items)
+	})
+}
+
+// RevokeOwnerStream removes the owners of OwnerKeys, or of the keyring, from each crumbl as it arrives on the passed channel, the crumbls in the options
+// being ignored, and sends the new crumbls in the same order, any data following a crumbl on the same item being ignored.
+// The returned channel is closed once the passed channel is closed or the context is done.
+// It only returns an error if the options are invalid; the failure of an item is held in its result.
+func RevokeOwnerStream(ctx context.Context, opts Options, items <-chan string) (<-chan Result, error) {
+	log := logger{opts.Diagnostics}
+	keys, err := buildKeySet(opts, log)
+	if err != nil {
+		return nil, err
+	}
+	owners := buildSigners(keys.owners, log)
+	if len(owners) == 0 {
+		return nil, errors.New("missing public keys for the owners to revoke")
+	}
+	emitter, err := buildEmitter(opts, log)
+	if err != nil {
+		return nil, err
+	}
+	emitters, err := buildEmitters(opts, log)
+	if err != nil {
+		return nil, err
+	}
+	return processStream(ctx, items, func(crumbled string) (string, error) {
+		revocation := core.Revocation{
+			Crumbled: crumbled,
+			Owners:   owners,
+			Emitter:  emitter,
+			Emitters: emitters,
+		}
+		return revocation.Process()
+	}), nil
+}
+
+// Inspect describes each passed crumbl without needing any key, either as a human-readable text or as a JSON object.
+// It only returns an error if the format is invalid; the failure of a crumbl is held in its result.
+func Inspect(crumbls []string, format Format) (results []Result, err error) {
+	if format == "" {
+		format = TEXT_FORMAT
+	}
+	if format != TEXT_FORMAT && format != JSON_FORMAT {
+		err = fmt.Errorf("invalid format: %s", format)
+		return
+	}
+	for i, crumbled := range crumbls {
+		res := Result{Index: i}
+		description, e := core.Inspect(crumbled)
+		if e != nil {
+			res.Err = e
+		} else if format == JSON_FORMAT {
+			res.Value, res.Err = description.JSON()
+		} else {
+			res.Value = description.String()
+		}
+		results = append(results, res)
+	}
+	return
+}
+
+// Authenticate checks that each crumbl in the options was signed by any of the emitters whose public keys are in the options,
+// the value of a result being the fingerprint of the emitter of the crumbl.
+// It only returns an error if there's no valid emitter key; the failure of a crumbl is held in its result.
+func Authenticate(opts Options) (results []Result, err error) {
+	emitters, err := buildEmitters(opts, logger{opts.Diagnostics})
+	if err != nil {
+		return
+	}
+	if len(emitters) == 0 {
+		err = errMissingEmitterKeys
+		return
+	}
+	for i, crumbled := range opts.Crumbls {
+		res := Result{Index: i}
+		parsed, e := core.Parse(crumbled)
+		if e == nil {
+			e = parsed.Verify(emitters)
+		}
+		if e != nil {
+			res.Err = e
+		} else {
+			res.Value = parsed.Emitter
+		}
+		results = append(results, res)
+	}
+	return
+}
+
+// buildTarget returns the crumbl template holding the owners and trusted signers of the passed keys along with the emitter,
+// the obfuscation key and the dispatching options of the options
+func buildTarget(opts Options, ownerKeys, signerKeys []publicKey, log logger) (target core.Crumbl, err error) {
+	if len(ownerKeys) == 0 {
+		err = errors.New("missing public key for the data owner")
+		return
+	}
+	if len(signerKeys) == 0 {
+		err = errors.New("missing public keys for trusted signers")
+		return
+	}
+	emitter, err := buildEmitter(opts, log)
+	if err != nil {
+		return
+	}
+	obfuscationKeys, err := buildObfuscationKeys(opts)
+	if err != nil {
+		return
+	}
+	target = core.Crumbl{
+		Owners:     buildSigners(ownerKeys, log),
+		Trustees:   buildSigners(signerKeys, log),
+		Redundancy: opts.Redundancy,
+		Threshold:  opts.Threshold,
+		Emitter:    emitter,
+	}
+	if len(obfuscationKeys) > 0 {
+		target.ObfuscationKey = obfuscationKeys[0]
+	}
+	return
+}
+
+// uncrumbls returns the items to uncrumble built from the ones arriving on the passed channel with the keys of the options,
+// failing unless the keys are those of an owner if ownerOnly is set
+func uncrumbls(ctx context.Context, opts Options, items <-chan string, ownerOnly bool) (<-chan core.Uncrumbl, error) {
+	log := logger{opts.Diagnostics}
+	keys, err := buildKeySet(opts, log)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys.owners) == 0 && len(keys.signers) == 0 {
+		return nil, errors.New("missing public key for the data owner or the trusted signer")
+	}
+	user, isOwner, err := buildUser(keys, log)
+	if err != nil {
+		return nil, err
+	}
+	if ownerOnly && !isOwner {
+		return nil, errors.New("invalid keys: the data owner's keys are expected")
+	}
+	emitters, err := buildEmitters(opts, log)
+	if err != nil {
+		return nil, err
+	}
+	obfuscationKeys, err := buildObfuscationKeys(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// As an owner, only the partial uncrumbs signed by the passed trustees or by the owner itself are trusted
+	var signers []signer.Signer
+	if isOwner {
+		if len(keys.signers) > 0 {
+			signers = append(buildSigners(keys.signers, log), signer.Signer{EncryptionAlgorithm: user.EncryptionAlgorithm, PublicKey: user.PublicKey})
+		} else if len(opts.PartialUncrumbs) > 0 {
+			log.warning("partial uncrumbs not authenticated: missing public keys of the trustees")
+		}
+	}
+
+	partialUncrumbs := groupUncrumbs(opts.PartialUncrumbs)
+	in := make(chan core.Uncrumbl)
+	go func() {
+		defer close(in)
+		for item := range forward(ctx, items) {
+			data := utils.RegexSplit(strings.TrimSpace(item), "\\s+")
+			uncrumbl := core.Uncrumbl{
+				Crumbled:        data[0],
+				Signer:          user,
+				IsOwner:         isOwner,
+				Emitters:        emitters,
+				ObfuscationKeys: obfuscationKeys,
+				Diagnostics:     opts.Diagnostics,
+			}
+			// An invalid crumbl is left to fail when processed
+			if crumbled, e := core.Parse(data[0]); e == nil {
+				if verificationHash, e := hasher.Unapply(crumbled.Hashered, crumbled.Crumbs); e == nil {
+					uncrumbl.Slices = parseUncrumbs(append(data[1:], partialUncrumbs[verificationHash]...), verificationHash, crumbled, signers, log)
+					uncrumbl.VerificationHash = verificationHash
+					if opts.VerificationHash != "" {
+						uncrumbl.VerificationHash = opts.VerificationHash
+					}
+				}
+			}
+			select {
+			case in <- uncrumbl:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return in, nil
+}
+
+// collectStream sends the passed items to the stream returned by the passed function and collects its results
+func collectStream(ctx context.Context, items []string, stream func(<-chan string) (<-chan Result, error)) (results []Result, err error) {
+	in := make(chan string)
+	out, err := stream(in)
+	if err != nil {
+		return
+	}
+	go func() {
+		defer close(in)
+		for _, item := range items {
+			select {
+			case in <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for res := range out {
+		results = append(results, res)
+	}
+	err = ctx.Err()
+	return
+}
+
+// processStream applies the passed function to the crumbl of each item as it arrives on the passed channel, any data following it being ignored,
+// and sends the results in the same order until the passed channel is closed or the context is done
+func processStream(ctx context.Context, items <-chan string, process func(crumbled string) (string, error)) <-chan Result {
+	results := make(chan Result)
+	go func() {
+		defer close(results)
+		index := 0
+		for item := range forward(ctx, items) {
+			res := Result{Index: index}
+			res.Value, res.Err = process(utils.RegexSplit(strings.TrimSpace(item), "\\s+")[0])
+			select {
+			case results <- res:
+			case <-ctx.Done():
+				return
+			}
+			index++
+		}
+	}()
+	return results
+}
+
+// forward sends the items of the passed channel until it's closed or the context is done
+func forward(ctx context.Context, items <-chan string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case item, ok := <-items:
+				if !ok {
+					return
+				}
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// processRecords reads the records one after the other until io.EOF, streams the values of each record to the passed process,
+// and hands each record back along with the results of its values as soon as they're all available, in the same order as read.
+func processRecords[R any](ctx context.Context, read func() (R, []string, error), process func(context.Context, <-chan string) (<-chan Result, error), handle func(R, []Result) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	values := make(chan string)
+	results, err := process(ctx, values)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Let the process end when the records are left unfinished
+		go func() {
+			for range results {
+			}
+		}()
+	}()
+
+	type pending struct {
+		record R
+		count  int
+	}
+	records := make(chan pending, 1024)
+	var readErr error
+	go func() {
+		defer close(values)
+		defer close(records)
+		for {
+			record, recordValues, e := read()
+			if e != nil {
+				if e != io.EOF {
+					readErr = e
+				}
+				return
+			}
+			select {
+			case records <- pending{record, len(recordValues)}:
+			case <-ctx.Done():
+				return
+			}
+			for _, value := range recordValues {
+				select {
+				case values <- value:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	for p := range records {
+		recordResults := make([]Result, 0, p.count)
+		for i := 0; i < p.count; i++ {
+			res, ok := <-results
+			if !ok {
+				return ctx.Err()
+			}
+			recordResults = append(recordResults, res)
+		}
+		if err = handle(p.record, recordResults); err != nil {
+			return err
+		}
+	}
+	if readErr != nil {
+		return readErr
+	}
+	return ctx.Err()
+}
+
+// toResults converts the passed batch results
+func toResults(batch <-chan core.BatchResult) <-chan Result {
+	results := make(chan Result)
+	go func() {
+		defer close(results)
+		for res := range batch {
+			results <- Result{
+				Index: res.Index,
+				Value: res.Result,
+				Err:   res.Err,
+			}
+		}
+	}()
+	return results
+}
+
+//--- ERRORS
+
+// ErrInvalidOptions is returned when the dispatching options or the hash engine of the crumbls to create are invalid
+var ErrInvalidOptions = errors.New("invalid options")
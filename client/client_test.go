@@ -0,0 +1,376 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/client"
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/obfuscator"
+
+	"gotest.tools/assert"
+)
+
+// TestCrumbleUncrumble ...
+func TestCrumbleUncrumble(t *testing.T) {
+	sources := []string{"cdever@edgewhere.fr", "contact@edgewhere.fr"}
+	var diagnostics bytes.Buffer
+
+	crumbled, err := client.Crumble(context.Background(), client.Options{
+		OwnerKeys:   "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys:  "ecies:" + dir + "crypto/ecies/keys/trustee1.pub,ecies:wrong/path.pub",
+		Sources:     sources,
+		Diagnostics: &diagnostics,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(crumbled), len(sources))
+	assert.Assert(t, bytes.Contains(diagnostics.Bytes(), []byte("WARNING - invalid file path in ecies:wrong/path.pub")))
+
+	crumbls := []string{crumbled[0].Value, "not-a-crumbl", crumbled[1].Value}
+	partials, err := client.Uncrumble(context.Background(), client.Options{
+		SignerKeys:   "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+		Crumbls:      crumbls,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(partials), len(crumbls))
+	assert.Assert(t, partials[1].Err != nil)
+
+	uncrumbled, err := client.Uncrumble(context.Background(), client.Options{
+		OwnerKeys:       "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret:     dir + "crypto/ecies/keys/owner1.sk",
+		Crumbls:         []string{crumbls[2], crumbls[0]},
+		PartialUncrumbs: []string{partials[0].Value, partials[2].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uncrumbled[0].Value, sources[1])
+	assert.Equal(t, uncrumbled[1].Value, sources[0])
+
+	// Partial uncrumbs are only trusted if signed by the passed trustees
+	diagnostics.Reset()
+	uncrumbled, err = client.Uncrumble(context.Background(), client.Options{
+		OwnerKeys:       "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret:     dir + "crypto/ecies/keys/owner1.sk",
+		SignerKeys:      "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		Crumbls:         []string{crumbls[0]},
+		PartialUncrumbs: []string{partials[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uncrumbled[0].Value, sources[0])
+	uncrumbled, err = client.Uncrumble(context.Background(), client.Options{
+		OwnerKeys:       "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret:     dir + "crypto/ecies/keys/owner1.sk",
+		SignerKeys:      "ecies:" + dir + "crypto/ecies/keys/signer.pub",
+		Crumbls:         []string{crumbls[0]},
+		PartialUncrumbs: []string{partials[0].Value},
+		Diagnostics:     &diagnostics,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, uncrumbled[0].Value != sources[0])
+	assert.Assert(t, strings.Contains(diagnostics.String(), "WARNING - rejected uncrumb: "+partials[0].Value+": unknown signer of partial uncrumbs"))
+
+	// Stripping the signature of partial uncrumbs, even relabelled with a version that doesn't sign them, doesn't make them trusted
+	unsigned := strings.SplitN(partials[0].Value, ".", 2)[0]
+	for _, stripped := range []string{unsigned + "." + core.VERSION, unsigned + ".6"} {
+		for _, signerKey := range []string{"trustee1.pub", "signer.pub"} {
+			diagnostics.Reset()
+			uncrumbled, err = client.Uncrumble(context.Background(), client.Options{
+				OwnerKeys:       "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+				OwnerSecret:     dir + "crypto/ecies/keys/owner1.sk",
+				SignerKeys:      "ecies:" + dir + "crypto/ecies/keys/" + signerKey,
+				Crumbls:         []string{crumbls[0]},
+				PartialUncrumbs: []string{stripped},
+				Diagnostics:     &diagnostics,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Assert(t, uncrumbled[0].Value != sources[0])
+			assert.Assert(t, strings.Contains(diagnostics.String(), "WARNING - rejected uncrumb: "+stripped+": "), diagnostics.String())
+		}
+	}
+
+	// Invalid options are returned as error
+	_, err = client.Crumble(context.Background(), client.Options{Sources: sources})
+	assert.Error(t, err, "missing public key for the data owner")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Crumble(ctx, client.Options{
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		Sources:    sources,
+	})
+	assert.Equal(t, err, context.Canceled)
+}
+
+// TestRekey ...
+func TestRekey(t *testing.T) {
+	sources := []string{"cdever@edgewhere.fr", "contact@edgewhere.fr"}
+	owner := client.Options{
+		OwnerKeys:   "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret: dir + "crypto/ecies/keys/owner1.sk",
+		SignerKeys:  "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		Sources:     sources,
+	}
+	crumbled, err := client.Crumble(context.Background(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crumbls := []string{crumbled[0].Value, crumbled[1].Value}
+	partials, err := client.Uncrumble(context.Background(), client.Options{
+		SignerKeys:   owner.SignerKeys,
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+		Crumbls:      crumbls,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner.Crumbls = crumbls
+	owner.PartialUncrumbs = []string{partials[0].Value}
+	owner.NewSignerKeys = "ecies:" + dir + "crypto/ecies/keys/signer.pub"
+	rekeyed, err := client.Rekey(context.Background(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(rekeyed), 2)
+	assert.NilError(t, rekeyed[0].Err)
+	assert.Assert(t, errors.Is(rekeyed[1].Err, core.ErrIncompleteUncrumbs))
+	verificationHash, _, _ := core.ExtractData(crumbls[0])
+	newVerificationHash, _, _ := core.ExtractData(rekeyed[0].Value)
+	assert.Equal(t, newVerificationHash, verificationHash)
+
+	partials, err = client.Uncrumble(context.Background(), client.Options{
+		SignerKeys:   owner.NewSignerKeys,
+		SignerSecret: dir + "crypto/ecies/keys/signer.sk",
+		Crumbls:      []string{rekeyed[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbled, err := client.Uncrumble(context.Background(), client.Options{
+		OwnerKeys:       owner.OwnerKeys,
+		OwnerSecret:     owner.OwnerSecret,
+		SignerKeys:      owner.NewSignerKeys,
+		Crumbls:         []string{rekeyed[0].Value},
+		PartialUncrumbs: []string{partials[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uncrumbled[0].Value, sources[0])
+
+	_, err = client.Rekey(context.Background(), client.Options{
+		SignerKeys:   owner.SignerKeys,
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+		Crumbls:      crumbls,
+	})
+	assert.Error(t, err, "missing public key for the data owner")
+	owner.NewOwnerKeys = owner.OwnerKeys
+	owner.OwnerSecret = ""
+	_, err = client.Rekey(context.Background(), owner)
+	assert.Equal(t, err, client.ErrNoSigner)
+}
+
+// TestAddOwner ...
+func TestAddOwner(t *testing.T) {
+	owner := client.Options{
+		OwnerKeys:   "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret: dir + "crypto/ecies/keys/owner1.sk",
+		SignerKeys:  "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		Sources:     []string{"cdever@edgewhere.fr"},
+	}
+	crumbled, err := client.Crumble(context.Background(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner.Crumbls = []string{crumbled[0].Value, "not-a-crumbl"}
+	owner.NewOwnerKeys = "ecies:" + dir + "crypto/ecies/keys/signer.pub"
+	delegated, err := client.AddOwner(context.Background(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NilError(t, delegated[0].Err)
+	assert.Assert(t, delegated[1].Err != nil)
+
+	partials, err := client.Uncrumble(context.Background(), client.Options{
+		SignerKeys:   owner.SignerKeys,
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+		Crumbls:      []string{delegated[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbled, err := client.Uncrumble(context.Background(), client.Options{
+		OwnerKeys:       owner.NewOwnerKeys,
+		OwnerSecret:     dir + "crypto/ecies/keys/signer.sk",
+		SignerKeys:      owner.SignerKeys,
+		Crumbls:         []string{delegated[0].Value},
+		PartialUncrumbs: []string{partials[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uncrumbled[0].Value, owner.Sources[0])
+
+	owner.NewOwnerKeys = ""
+	_, err = client.AddOwner(context.Background(), owner)
+	assert.Error(t, err, "missing public keys for the new owners")
+}
+
+// TestInspect ...
+func TestInspect(t *testing.T) {
+	crumbled, err := client.Crumble(context.Background(), client.Options{
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		Sources:    []string{"cdever@edgewhere.fr"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := client.Inspect([]string{crumbled[0].Value, "not-a-crumbl"}, client.JSON_FORMAT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(results), 2)
+	assert.Assert(t, strings.HasPrefix(results[0].Value, `{"version":"`+core.VERSION+`","hashEngine":"`+crypto.DEFAULT_HASH_ENGINE+`","hashered":"`+crumbled[0].Value[:64]))
+	assert.Assert(t, results[1].Err != nil)
+
+	results, _ = client.Inspect([]string{crumbled[0].Value[:10]}, "")
+	assert.Assert(t, results[0].Err != nil)
+	results, _ = client.Inspect([]string{crumbled[0].Value}, client.TEXT_FORMAT)
+	assert.Assert(t, strings.Contains(results[0].Value, "slices: 2"))
+
+	_, err = client.Inspect([]string{crumbled[0].Value}, "xml")
+	assert.Error(t, err, "invalid format: xml")
+}
+
+// TestObfuscationKeys ...
+func TestObfuscationKeys(t *testing.T) {
+	path := t.TempDir() + "/tenant"
+	keyPath, err := client.GenerateObfuscationKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, keyPath, path+client.OBFUSCATION_KEY_EXTENSION)
+	_, err = client.GenerateObfuscationKeyFile(path)
+	assert.Assert(t, err != nil)
+
+	opts := client.Options{
+		OwnerKeys:       "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys:      "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		ObfuscationKeys: keyPath,
+		Sources:         []string{"cdever@edgewhere.fr"},
+	}
+	crumbled, err := client.Crumble(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	description, _ := client.Inspect([]string{crumbled[0].Value}, client.TEXT_FORMAT)
+	assert.Assert(t, strings.Contains(description[0].Value, "obfuscation key: "))
+
+	partials, err := client.Uncrumble(context.Background(), client.Options{
+		SignerKeys:   opts.SignerKeys,
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+		Crumbls:      []string{crumbled[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumble := client.Options{
+		OwnerKeys:       opts.OwnerKeys,
+		OwnerSecret:     dir + "crypto/ecies/keys/owner1.sk",
+		Crumbls:         []string{crumbled[0].Value},
+		PartialUncrumbs: []string{partials[0].Value},
+	}
+	uncrumbled, err := client.Uncrumble(context.Background(), uncrumble)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, errors.Is(uncrumbled[0].Err, core.ErrUnknownObfuscationKey))
+	uncrumble.ObfuscationKeys = keyPath
+	uncrumbled, err = client.Uncrumble(context.Background(), uncrumble)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uncrumbled[0].Value, opts.Sources[0])
+
+	malformed := t.TempDir() + "/malformed.key"
+	if err = os.WriteFile(malformed, []byte("sha-256:1:key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts.ObfuscationKeys = keyPath + "," + malformed
+	_, err = client.Crumble(context.Background(), opts)
+	assert.Assert(t, errors.Is(err, obfuscator.ErrInvalidKey))
+}
+
+// TestAuthenticate ...
+func TestAuthenticate(t *testing.T) {
+	opts := client.Options{
+		OwnerKeys:     "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys:    "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		EmitterKeys:   "ecies:" + dir + "crypto/ecies/keys/emitter.pub",
+		EmitterSecret: dir + "crypto/ecies/keys/emitter.sk",
+		Sources:       []string{"cdever@edgewhere.fr"},
+	}
+	signed, err := client.Crumble(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.EmitterSecret = ""
+	unsigned, err := client.Crumble(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := client.Authenticate(client.Options{
+		EmitterKeys: "ecies:" + dir + "crypto/ecies/keys/emitter.pub",
+		Crumbls:     []string{signed[0].Value, unsigned[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, results[0].Err == nil && results[0].Value != "")
+	assert.Assert(t, errors.Is(results[1].Err, core.ErrUnsignedCrumbl))
+	description, _ := client.Inspect([]string{signed[0].Value}, client.TEXT_FORMAT)
+	assert.Assert(t, strings.Contains(description[0].Value, "signed by emitter: "+results[0].Value))
+
+	// Only the crumbls of the authorized emitters are uncrumbled
+	results, err = client.Uncrumble(context.Background(), client.Options{
+		SignerKeys:   "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+		EmitterKeys:  "ecies:" + dir + "crypto/ecies/keys/signer.pub",
+		Crumbls:      []string{signed[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, errors.Is(results[0].Err, core.ErrUnknownEmitter))
+
+	_, err = client.Authenticate(client.Options{EmitterKeys: "ecies:wrong/path.pub"})
+	assert.Error(t, err, "missing public keys for authorized emitters")
+	_, err = client.Crumble(context.Background(), client.Options{
+		OwnerKeys:     opts.OwnerKeys,
+		SignerKeys:    opts.SignerKeys,
+		EmitterSecret: dir + "crypto/ecies/keys/emitter.sk",
+		Sources:       opts.Sources,
+	})
+	assert.Error(t, err, "invalid keys: a single public key is expected for the emitter")
+}
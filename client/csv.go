@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//--- TYPES
+
+// CSVOptions tells which columns of a CSV file to crumble or uncrumble, every other column being copied as is.
+type CSVOptions struct {
+	Columns  []string // The names of the columns in the header, or their position starting at 1 as with `cut`
+	NoHeader bool     // Set to `true` if the first row is not a header, the columns then being only selected by position
+	Comma    rune     // Optional: the field delimiter, defaults to a comma
+}
+
+//--- METHODS
+
+// indices returns the zero-based indices of the selected columns, looking first for their name in the passed header if any
+func (o CSVOptions) indices(header []string) (indices []int, err error) {
+	selected := make(map[int]bool)
+	for _, column := range o.Columns {
+		column = strings.TrimSpace(column)
+		index := -1
+		for i, name := range header {
+			if strings.TrimSpace(name) == column {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			position, e := strconv.Atoi(column)
+			if e != nil || position < 1 {
+				err = fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+				return
+			}
+			index = position - 1
+		}
+		if !selected[index] {
+			selected[index] = true
+			indices = append(indices, index)
+		}
+	}
+	if len(indices) == 0 {
+		err = errors.New("no column to process")
+	}
+	return
+}
+
+//--- FUNCTIONS
+
+// CrumbleCSV replaces each non-empty cell of the selected columns of the CSV read from r with its crumbl, writing the rows to w as soon as they're processed,
+// so that a file of any size may be crumbled.
+// A failing cell doesn't stop the process: its error is sent to the diagnostics writer of the options and the cell is left empty.
+// The sources and the verification hash in the options are ignored.
+func CrumbleCSV(ctx context.Context, opts Options, csvOpts CSVOptions, r io.Reader, w io.Writer) error {
+	opts.VerificationHash = ""
+	return processCSV(ctx, csvOpts, r, w, logger{opts.Diagnostics}, func(ctx context.Context, cells <-chan string) (<-chan Result, error) {
+		return CrumbleStream(ctx, opts, cells)
+	})
+}
+
+// UncrumbleCSV replaces each non-empty cell of the selected columns of the CSV read from r with its uncrumbled value, writing the rows to w as soon as they're processed,
+// ie. with the source when the owner's keys are passed or with the partial uncrumbs when the keys are those of a trusted signer.
+// As in UncrumbleStream, a cell may hold its crumbl followed by its own partial uncrumbs, the partial uncrumbs in the options being dispatched to every cell.
+// A failing cell doesn't stop the process: its error is sent to the diagnostics writer of the options and the cell is left empty.
+// The crumbls and the verification hash in the options are ignored.
+func UncrumbleCSV(ctx context.Context, opts Options, csvOpts CSVOptions, r io.Reader, w io.Writer) error {
+	opts.VerificationHash = ""
+	return processCSV(ctx, csvOpts, r, w, logger{opts.Diagnostics}, func(ctx context.Context, cells <-chan string) (<-chan Result, error) {
+		return UncrumbleStream(ctx, opts, cells)
+	})
+}
+
+// processCSV streams the selected cells of each row to the passed process and writes the row back once all its cells are processed
+func processCSV(ctx context.Context, csvOpts CSVOptions, r io.Reader, w io.Writer, log logger, process func(context.Context, <-chan string) (<-chan Result, error)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // A short row simply lacks the missing cells
+	writer := csv.NewWriter(w)
+	if csvOpts.Comma != 0 {
+		reader.Comma = csvOpts.Comma
+		writer.Comma = csvOpts.Comma
+	}
+	var header []string
+	if !csvOpts.NoHeader {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		header = record
+		if err = writer.Write(header); err != nil {
+			return err
+		}
+	}
+	columns, err := csvOpts.indices(header)
+	if err != nil {
+		return err
+	}
+
+	number := 0 // The number of the current row, counting the header if any
+	if header != nil {
+		number = 1
+	}
+	successes, failures := 0, 0
+	read := func() (record []string, values []string, err error) {
+		record, err = reader.Read()
+		for _, column := range columns {
+			if column < len(record) && record[column] != "" {
+				values = append(values, record[column])
+			}
+		}
+		return
+	}
+	handle := func(row []string, results []Result) error {
+		number++
+		for _, column := range columns {
+			if column >= len(row) || row[column] == "" {
+				continue
+			}
+			res := results[0]
+			results = results[1:]
+			if res.Err != nil {
+				log.error(fmt.Errorf("row %d, column %d: %w", number, column+1, res.Err))
+				row[column] = ""
+				failures++
+				continue
+			}
+			row[column] = res.Value
+			successes++
+		}
+		return writer.Write(row)
+	}
+	if err = processRecords(ctx, read, process, handle); err != nil {
+		return err
+	}
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return err
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d cell(s) failed", failures, successes+failures)
+	}
+	return nil
+}
+
+//--- ERRORS
+
+// ErrUnknownColumn is returned when a selected column is neither in the header of the CSV file nor a valid position
+var ErrUnknownColumn = errors.New("unknown column")
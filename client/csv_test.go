@@ -0,0 +1,102 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/client"
+	"github.com/cyrildever/crumbl-exe/core"
+
+	"gotest.tools/assert"
+)
+
+// TestCrumbleUncrumbleCSV ...
+func TestCrumbleUncrumbleCSV(t *testing.T) {
+	export := "name,email,phone\n" +
+		"Cyril,cdever@edgewhere.fr,+33600000000\n" +
+		"\"Edgewhere, Inc.\",,+33100000000\n" +
+		"Contact,contact@edgewhere.fr\n"
+	csvOpts := client.CSVOptions{Columns: []string{"email", "3"}}
+
+	var crumbled bytes.Buffer
+	err := client.CrumbleCSV(context.Background(), client.Options{
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+	}, csvOpts, strings.NewReader(export), &crumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := readCSV(crumbled.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.DeepEqual(t, rows[0], []string{"name", "email", "phone"})
+	assert.Equal(t, rows[1][0], "Cyril")
+	_, err = core.Parse(rows[1][1])
+	assert.NilError(t, err)
+	_, err = core.Parse(rows[1][2])
+	assert.NilError(t, err)
+	assert.Equal(t, rows[2][0], "Edgewhere, Inc.")
+	assert.Equal(t, rows[2][1], "")
+	assert.Equal(t, len(rows[3]), 2)
+
+	// Partial uncrumbling by the trustee
+	var partials bytes.Buffer
+	err = client.UncrumbleCSV(context.Background(), client.Options{
+		SignerKeys:   "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+	}, csvOpts, bytes.NewReader(crumbled.Bytes()), &partials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partialRows, _ := readCSV(partials.String())
+	var partialUncrumbs []string
+	for _, row := range partialRows[1:] {
+		for _, cell := range row[1:] {
+			if cell != "" {
+				partialUncrumbs = append(partialUncrumbs, cell)
+			}
+		}
+	}
+	assert.Equal(t, len(partialUncrumbs), 4)
+
+	// Full uncrumbling by the owner
+	var uncrumbled bytes.Buffer
+	err = client.UncrumbleCSV(context.Background(), client.Options{
+		OwnerKeys:       "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret:     dir + "crypto/ecies/keys/owner1.sk",
+		PartialUncrumbs: partialUncrumbs,
+	}, csvOpts, bytes.NewReader(crumbled.Bytes()), &uncrumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uncrumbled.String(), export)
+
+	// Failing cells are left empty
+	var failed, diagnostics bytes.Buffer
+	err = client.UncrumbleCSV(context.Background(), client.Options{
+		OwnerKeys:   "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret: dir + "crypto/ecies/keys/owner1.sk",
+		Diagnostics: &diagnostics,
+	}, client.CSVOptions{Columns: []string{"2"}, NoHeader: true, Comma: ';'}, strings.NewReader("Cyril;not-a-crumbl\n"), &failed)
+	assert.Error(t, err, "1 of 1 cell(s) failed")
+	assert.Equal(t, failed.String(), "Cyril;\n")
+	assert.Assert(t, strings.HasPrefix(diagnostics.String(), "ERROR - row 1, column 2: "))
+
+	// Unknown columns are returned as error
+	err = client.CrumbleCSV(context.Background(), client.Options{
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+	}, client.CSVOptions{Columns: []string{"iban"}}, strings.NewReader(export), &bytes.Buffer{})
+	assert.Assert(t, errors.Is(err, client.ErrUnknownColumn))
+}
+
+func readCSV(content string) ([][]string, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
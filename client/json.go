@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cyrildever/crumbl-exe/utils/jsonpath"
+)
+
+//--- TYPES
+
+// JSONOptions tells which string fields of JSON documents to crumble or uncrumble, the rest of the documents being left untouched.
+type JSONOptions struct {
+	Paths []string // The JSON path selectors of the fields, eg. `$.customer.email` or `$.payments[*].iban` (see the jsonpath package)
+}
+
+// document is a JSON document along with its selected fields
+type document struct {
+	data    []byte
+	matches []jsonpath.Match
+}
+
+//--- FUNCTIONS
+
+// CrumbleJSON replaces each non-empty string field selected in the JSON documents read from r with its crumbl, writing the documents to w as soon as they're processed,
+// so that a JSON Lines file of any size may be crumbled. Each document is written on its own line, exactly as it was read apart from the selected fields.
+// A failing field doesn't stop the process: its error is sent to the diagnostics writer of the options and the field is left empty.
+// The sources and the verification hash in the options are ignored.
+func CrumbleJSON(ctx context.Context, opts Options, jsonOpts JSONOptions, r io.Reader, w io.Writer) error {
+	opts.VerificationHash = ""
+	return processJSON(ctx, jsonOpts, r, w, logger{opts.Diagnostics}, func(ctx context.Context, fields <-chan string) (<-chan Result, error) {
+		return CrumbleStream(ctx, opts, fields)
+	})
+}
+
+// UncrumbleJSON replaces each non-empty string field selected in the JSON documents read from r with its uncrumbled value, writing the documents to w as soon as they're processed,
+// ie. with the source when the owner's keys are passed or with the partial uncrumbs when the keys are those of a trusted signer.
+// As in UncrumbleStream, a field may hold its crumbl followed by its own partial uncrumbs, the partial uncrumbs in the options being dispatched to every field.
+// A failing field doesn't stop the process: its error is sent to the diagnostics writer of the options and the field is left empty.
+// The crumbls and the verification hash in the options are ignored.
+func UncrumbleJSON(ctx context.Context, opts Options, jsonOpts JSONOptions, r io.Reader, w io.Writer) error {
+	opts.VerificationHash = ""
+	return processJSON(ctx, jsonOpts, r, w, logger{opts.Diagnostics}, func(ctx context.Context, fields <-chan string) (<-chan Result, error) {
+		return UncrumbleStream(ctx, opts, fields)
+	})
+}
+
+// processJSON streams the selected fields of each document to the passed process and writes the document back once all its fields are processed
+func processJSON(ctx context.Context, jsonOpts JSONOptions, r io.Reader, w io.Writer, log logger, process func(context.Context, <-chan string) (<-chan Result, error)) error {
+	var paths []jsonpath.Path
+	for _, selector := range jsonOpts.Paths {
+		path, err := jsonpath.Parse(strings.TrimSpace(selector))
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return errors.New("no JSON path to process")
+	}
+
+	decoder := json.NewDecoder(r)
+	number := 0
+	successes, failures := 0, 0
+	read := func() (doc document, values []string, err error) {
+		var raw json.RawMessage
+		if err = decoder.Decode(&raw); err != nil {
+			if err != io.EOF {
+				err = fmt.Errorf("%w: %v", jsonpath.ErrInvalidDocument, err)
+			}
+			return
+		}
+		matches, err := jsonpath.Find(raw, paths...)
+		if err != nil {
+			return
+		}
+		doc.data = raw
+		for _, m := range matches {
+			if m.Value != "" {
+				doc.matches = append(doc.matches, m)
+				values = append(values, m.Value)
+			}
+		}
+		return
+	}
+	handle := func(doc document, results []Result) error {
+		number++
+		replacements := make([]string, len(results))
+		for i, res := range results {
+			if res.Err != nil {
+				log.error(fmt.Errorf("document %d, %s: %w", number, doc.matches[i].Path, res.Err))
+				failures++
+				continue
+			}
+			replacements[i] = res.Value
+			successes++
+		}
+		replaced, err := jsonpath.Replace(doc.data, doc.matches, replacements)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(replaced, '\n'))
+		return err
+	}
+	if err := processRecords(ctx, read, process, handle); err != nil {
+		return err
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d field(s) failed", failures, successes+failures)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/client"
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/utils/jsonpath"
+
+	"gotest.tools/assert"
+)
+
+// TestCrumbleUncrumbleJSON ...
+func TestCrumbleUncrumbleJSON(t *testing.T) {
+	events := `{"customer": {"email": "cdever@edgewhere.fr", "age": 42}, "payments": [{"iban": "FR7630006000011234567890189"}, {"iban": ""}]}
+{"type":"ping"}
+{
+  "customer": {"email": "contact@edgewhere.fr"}
+}
+`
+	jsonOpts := client.JSONOptions{Paths: []string{"$.customer.email", "$.payments[*].iban"}}
+
+	var crumbled bytes.Buffer
+	err := client.CrumbleJSON(context.Background(), client.Options{
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+	}, jsonOpts, strings.NewReader(events), &crumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first struct {
+		Customer struct {
+			Email string `json:"email"`
+			Age   int    `json:"age"`
+		} `json:"customer"`
+		Payments []struct {
+			IBAN string `json:"iban"`
+		} `json:"payments"`
+	}
+	documents := strings.SplitN(crumbled.String(), "\n", 3)
+	err = json.Unmarshal([]byte(documents[0]), &first)
+	assert.NilError(t, err)
+	_, err = core.Parse(first.Customer.Email)
+	assert.NilError(t, err)
+	_, err = core.Parse(first.Payments[0].IBAN)
+	assert.NilError(t, err)
+	assert.Equal(t, first.Customer.Age, 42)
+	assert.Equal(t, first.Payments[1].IBAN, "")
+	assert.Equal(t, documents[1], `{"type":"ping"}`)
+
+	// Partial uncrumbling by the trustee
+	var partials bytes.Buffer
+	err = client.UncrumbleJSON(context.Background(), client.Options{
+		SignerKeys:   "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+	}, jsonOpts, bytes.NewReader(crumbled.Bytes()), &partials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var partialUncrumbs []string
+	var paths []jsonpath.Path
+	for _, selector := range jsonOpts.Paths {
+		path, _ := jsonpath.Parse(selector)
+		paths = append(paths, path)
+	}
+	decoder := json.NewDecoder(&partials)
+	for decoder.More() {
+		var document json.RawMessage
+		assert.NilError(t, decoder.Decode(&document))
+		matches, err := jsonpath.Find(document, paths...)
+		assert.NilError(t, err)
+		for _, m := range matches {
+			if m.Value != "" {
+				partialUncrumbs = append(partialUncrumbs, m.Value)
+			}
+		}
+	}
+	assert.Equal(t, len(partialUncrumbs), 3)
+
+	// Full uncrumbling by the owner
+	var uncrumbled bytes.Buffer
+	err = client.UncrumbleJSON(context.Background(), client.Options{
+		OwnerKeys:       "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret:     dir + "crypto/ecies/keys/owner1.sk",
+		PartialUncrumbs: partialUncrumbs,
+	}, jsonOpts, bytes.NewReader(crumbled.Bytes()), &uncrumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uncrumbled.String(), events)
+
+	// Failing fields are left empty
+	var failed, diagnostics bytes.Buffer
+	err = client.UncrumbleJSON(context.Background(), client.Options{
+		OwnerKeys:   "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret: dir + "crypto/ecies/keys/owner1.sk",
+		Diagnostics: &diagnostics,
+	}, jsonOpts, strings.NewReader(`{"customer":{"email":"not-a-crumbl"}}`), &failed)
+	assert.Error(t, err, "1 of 1 field(s) failed")
+	assert.Equal(t, failed.String(), `{"customer":{"email":""}}`+"\n")
+	assert.Assert(t, strings.HasPrefix(diagnostics.String(), "ERROR - document 1, $.customer.email: "))
+
+	// Invalid paths or documents are returned as error
+	err = client.CrumbleJSON(context.Background(), client.Options{
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+	}, client.JSONOptions{Paths: []string{"customer.email"}}, strings.NewReader(events), &bytes.Buffer{})
+	assert.Assert(t, errors.Is(err, jsonpath.ErrInvalidPath))
+	err = client.CrumbleJSON(context.Background(), client.Options{
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+	}, jsonOpts, strings.NewReader(`{"customer": }`), &bytes.Buffer{})
+	assert.Assert(t, errors.Is(err, jsonpath.ErrInvalidDocument))
+}
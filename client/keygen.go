@@ -0,0 +1,80 @@
+package client
+
+import (
+	"errors"
+	"os"
+
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/obfuscator"
+)
+
+const (
+	// PUBLIC_KEY_EXTENSION ...
+	PUBLIC_KEY_EXTENSION = ".pub"
+
+	// SECRET_KEY_EXTENSION ...
+	SECRET_KEY_EXTENSION = ".sk"
+
+	// OBFUSCATION_KEY_EXTENSION ...
+	OBFUSCATION_KEY_EXTENSION = ".key"
+
+	// OBFUSCATION_ALGORITHM is the -keygen value generating an obfuscation key instead of a key pair
+	OBFUSCATION_ALGORITHM = "obfuscation"
+)
+
+// GenerateKeyFiles creates a new key pair for the passed encryption algorithm and saves it to the path suffixed with the
+// SECRET_KEY_EXTENSION and PUBLIC_KEY_EXTENSION, in the formats expected by the key flags of the executable.
+// The secret key file is only readable by its owner, and no existing file is ever overwritten.
+func GenerateKeyFiles(algo string, bits int, path string) (secretKeyPath, publicKeyPath string, err error) {
+	if path == "" {
+		err = errors.New("missing path to save the key files to")
+		return
+	}
+	sk, pk, err := crypto.GenerateKeyPair(algo, bits)
+	if err != nil {
+		return
+	}
+	secretKeyPath = path + SECRET_KEY_EXTENSION
+	publicKeyPath = path + PUBLIC_KEY_EXTENSION
+	if fileExists(secretKeyPath) || fileExists(publicKeyPath) {
+		err = errors.New("key files already exist at " + path)
+		return
+	}
+	if err = writeNewFile(secretKeyPath, sk, 0600); err != nil {
+		return
+	}
+	if err = writeNewFile(publicKeyPath, pk, 0644); err != nil {
+		os.Remove(secretKeyPath)
+	}
+	return
+}
+
+// GenerateObfuscationKeyFile creates a new random obfuscation key and saves it to the path suffixed with the
+// OBFUSCATION_KEY_EXTENSION, in the format expected by the -obfuscation-keys flag of the executable.
+// The file is only readable by its owner, and no existing file is ever overwritten.
+func GenerateObfuscationKeyFile(path string) (keyPath string, err error) {
+	if path == "" {
+		err = errors.New("missing path to save the key file to")
+		return
+	}
+	key, err := obfuscator.GenerateKey()
+	if err != nil {
+		return
+	}
+	keyPath = path + OBFUSCATION_KEY_EXTENSION
+	err = writeNewFile(keyPath, []byte(key.String()), 0600)
+	return
+}
+
+// writeNewFile writes the data to the passed file, failing if it already exists
+func writeNewFile(filename string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
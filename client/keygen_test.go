@@ -0,0 +1,53 @@
+package client_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/client"
+	"github.com/cyrildever/crumbl-exe/crypto"
+
+	"gotest.tools/assert"
+)
+
+// TestGenerateKeyFiles ...
+func TestGenerateKeyFiles(t *testing.T) {
+	tmp := t.TempDir()
+	ownerSK, ownerPK, err := client.GenerateKeyFiles(crypto.ECIES_ALGORITHM, 0, tmp+"/owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(ownerSK)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0600))
+	_, trusteePK, err := client.GenerateKeyFiles(crypto.RSA_ALGORITHM, 0, tmp+"/trustee")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The generated files should be usable as is
+	results, err := client.Crumble(context.Background(), client.Options{
+		OwnerKeys:  "ecies:" + ownerPK,
+		SignerKeys: "rsa:" + trusteePK,
+		Sources:    []string{"cdever@edgewhere.fr"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NilError(t, results[0].Err)
+	results, err = client.Uncrumble(context.Background(), client.Options{
+		SignerKeys:   "rsa:" + trusteePK,
+		SignerSecret: tmp + "/trustee.sk",
+		Crumbls:      []string{results[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NilError(t, results[0].Err)
+
+	// Existing files must not be overwritten
+	_, _, err = client.GenerateKeyFiles(crypto.ECIES_ALGORITHM, 0, tmp+"/owner")
+	assert.Assert(t, err != nil)
+	_, _, err = client.GenerateKeyFiles("dsa", 0, tmp+"/other")
+	assert.Assert(t, err != nil)
+}
@@ -0,0 +1,254 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+)
+
+//--- TYPES
+
+// Keyring describes the stakeholders of the crumbls in a JSON file, as an alternative to the comma-separated key options, eg.
+//
+//	{
+//	  "stakeholders": [
+//	    { "name": "me", "role": "owner", "algorithm": "ecies", "publicKeyPath": "myKey.pub", "secretKeyPath": "myKey.sk" },
+//	    { "name": "edgewhere", "role": "trustee", "algorithm": "ecies", "publicKey": "04e315a..." }
+//	  ]
+//	}
+//
+// The relative paths are relative to the directory of the keyring file, and the stakeholders keep the order of the file.
+// At most one owner and one trustee may have a secret key, the owner's being used first when uncrumbling.
+type Keyring struct {
+	Stakeholders []Stakeholder `json:"stakeholders"`
+}
+
+// Stakeholder is an owner or a trustee of a keyring, whose public key is either held in the file or in a key file
+type Stakeholder struct {
+	Name          string `json:"name"`
+	Role          Role   `json:"role"`
+	Algorithm     string `json:"algorithm"`
+	PublicKey     string `json:"publicKey,omitempty"`     // The public key in the format of the key files, exclusive of PublicKeyPath
+	PublicKeyPath string `json:"publicKeyPath,omitempty"` // The filepath to the public key, exclusive of PublicKey
+	SecretKeyPath string `json:"secretKeyPath,omitempty"` // Optional: the filepath to the private key of the stakeholder using the keyring
+}
+
+// Role ...
+type Role string
+
+const (
+	OWNER_ROLE   Role = "owner"
+	TRUSTEE_ROLE Role = "trustee"
+)
+
+// publicKey is the content of a public key file along with its encryption algorithm
+type publicKey struct {
+	algorithm string
+	key       string
+}
+
+// keySet holds the public keys of the owners and trusted signers in the order passed, along with the filepaths to the private keys
+// of the owner or the trusted signer using them. If unset, the public key of such a private key is the single one of its role.
+type keySet struct {
+	owners       []publicKey
+	signers      []publicKey
+	ownerKey     publicKey
+	ownerSecret  string
+	signerKey    publicKey
+	signerSecret string
+	owner        *signer.Signer // Optional: the owner with its private key once read (see LoadKeys)
+	trustee      *signer.Signer // Optional: the trusted signer with its private key once read (see LoadKeys)
+	strict       bool           // Whether the keys come from a validated keyring, an unusable private key being an error rather than a warning
+}
+
+//--- METHODS
+
+// Validate checks every stakeholder of the keyring, reading their key files, and returns the first error found
+func (k Keyring) Validate() error {
+	if len(k.Stakeholders) == 0 {
+		return fmt.Errorf("%w: no stakeholder", ErrInvalidKeyring)
+	}
+	names := make(map[string]bool)
+	fingerprints := make(map[string]string)
+	secrets := make(map[Role]bool)
+	for i, s := range k.Stakeholders {
+		if s.Name == "" {
+			return fmt.Errorf("%w: missing name of stakeholder #%d", ErrInvalidKeyring, i+1)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("%w: duplicate stakeholder %s", ErrInvalidKeyring, s.Name)
+		}
+		names[s.Name] = true
+		if s.Role != OWNER_ROLE && s.Role != TRUSTEE_ROLE {
+			return fmt.Errorf("%w: invalid role of %s: %q", ErrInvalidKeyring, s.Name, s.Role)
+		}
+		if !crypto.ExistsAlgorithm(s.Algorithm) {
+			return fmt.Errorf("%w: invalid encryption algorithm of %s: %q", ErrInvalidKeyring, s.Name, s.Algorithm)
+		}
+		pk, err := s.publicKey()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidKeyring, err)
+		}
+		pubkey, err := crypto.GetKeyBytes(pk.key, pk.algorithm)
+		if err != nil {
+			return fmt.Errorf("%w: invalid public key of %s: %v", ErrInvalidKeyring, s.Name, err)
+		}
+		fingerprint, err := crypto.Fingerprint(pubkey, pk.algorithm)
+		if err != nil {
+			return fmt.Errorf("%w: invalid public key of %s: %v", ErrInvalidKeyring, s.Name, err)
+		}
+		if other, found := fingerprints[fingerprint]; found {
+			return fmt.Errorf("%w: %s and %s share the same public key", ErrInvalidKeyring, other, s.Name)
+		}
+		fingerprints[fingerprint] = s.Name
+		if s.SecretKeyPath == "" {
+			continue
+		}
+		if secrets[s.Role] {
+			return fmt.Errorf("%w: more than one %s with a secret key", ErrInvalidKeyring, s.Role)
+		}
+		secrets[s.Role] = true
+		privkey, err := readSecretKey(s.SecretKeyPath, pk.algorithm)
+		if err != nil {
+			return fmt.Errorf("%w: invalid secret key of %s: %v", ErrInvalidKeyring, s.Name, err)
+		}
+		// The secret key must be the one of the public key
+		message := []byte(s.Name)
+		signature, err := crypto.Sign(message, privkey, pk.algorithm)
+		if err == nil {
+			err = crypto.Verify(message, signature, pubkey, pk.algorithm)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: secret key of %s not matching its public key", ErrInvalidKeyring, s.Name)
+		}
+	}
+	return nil
+}
+
+// keySet returns the keys of the stakeholders of the keyring
+func (k Keyring) keySet() (keys keySet, err error) {
+	keys.strict = true
+	for _, s := range k.Stakeholders {
+		pk, e := s.publicKey()
+		if e != nil {
+			return keys, fmt.Errorf("%w: %v", ErrInvalidKeyring, e)
+		}
+		if s.Role == OWNER_ROLE {
+			keys.owners = append(keys.owners, pk)
+			if s.SecretKeyPath != "" {
+				keys.ownerKey, keys.ownerSecret = pk, s.SecretKeyPath
+			}
+		} else {
+			keys.signers = append(keys.signers, pk)
+			if s.SecretKeyPath != "" {
+				keys.signerKey, keys.signerSecret = pk, s.SecretKeyPath
+			}
+		}
+	}
+	return
+}
+
+// ownerOnly returns the keys of the owners along with the owner's private key, if any
+func (k keySet) ownerOnly() keySet {
+	return keySet{owners: k.owners, ownerKey: k.ownerKey, ownerSecret: k.ownerSecret, owner: k.owner, strict: k.strict}
+}
+
+// signerOnly returns the keys of the trusted signers along with the trusted signer's private key, if any
+func (k keySet) signerOnly() keySet {
+	return keySet{signers: k.signers, signerKey: k.signerKey, signerSecret: k.signerSecret, trustee: k.trustee, strict: k.strict}
+}
+
+// publicKey returns the public key of the stakeholder, reading its file if need be
+func (s Stakeholder) publicKey() (pk publicKey, err error) {
+	pk.algorithm = s.Algorithm
+	if (s.PublicKey == "") == (s.PublicKeyPath == "") {
+		err = fmt.Errorf("either the public key or its filepath is expected for %s", s.Name)
+		return
+	}
+	if s.PublicKey != "" {
+		pk.key = strings.TrimSpace(s.PublicKey)
+		return
+	}
+	content, err := os.ReadFile(s.PublicKeyPath)
+	if err != nil {
+		err = fmt.Errorf("unreadable public key of %s: %v", s.Name, err)
+		return
+	}
+	pk.key = strings.Trim(string(content), "\n")
+	return
+}
+
+//--- FUNCTIONS
+
+// LoadKeyring reads and validates the keyring at the passed path, failing on any unknown field or invalid stakeholder
+func LoadKeyring(path string) (keyring Keyring, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&keyring); err != nil {
+		err = fmt.Errorf("%w: %s: %v", ErrInvalidKeyring, path, err)
+		return
+	}
+	if decoder.More() {
+		err = fmt.Errorf("%w: %s: unexpected data after the keyring", ErrInvalidKeyring, path)
+		return
+	}
+	dir := filepath.Dir(path)
+	for i, s := range keyring.Stakeholders {
+		keyring.Stakeholders[i].PublicKeyPath = relativeTo(dir, s.PublicKeyPath)
+		keyring.Stakeholders[i].SecretKeyPath = relativeTo(dir, s.SecretKeyPath)
+	}
+	err = keyring.Validate()
+	return
+}
+
+// buildKeySet returns the keys of the keyring of the options if any, of the key options otherwise,
+// failing if both are passed so that a keyring is never silently mixed up with other keys
+func buildKeySet(opts Options, log logger) (keys keySet, err error) {
+	if opts.Keys != nil {
+		return opts.Keys.keys, nil
+	}
+	if opts.Keyring != "" {
+		if opts.OwnerKeys != "" || opts.OwnerSecret != "" || opts.SignerKeys != "" || opts.SignerSecret != "" {
+			err = errors.New("invalid keys: a keyring excludes the owner and signer keys options")
+			return
+		}
+		keyring, e := LoadKeyring(opts.Keyring)
+		if e != nil {
+			return keys, e
+		}
+		return keyring.keySet()
+	}
+	if keys.owners, err = readPublicKeys(opts.OwnerKeys, log); err != nil {
+		return
+	}
+	if keys.signers, err = readPublicKeys(opts.SignerKeys, log); err != nil {
+		return
+	}
+	keys.ownerSecret = opts.OwnerSecret
+	keys.signerSecret = opts.SignerSecret
+	return
+}
+
+// relativeTo returns the passed path relative to the passed directory unless absolute or empty
+func relativeTo(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+//--- ERRORS
+
+// ErrInvalidKeyring is returned when a keyring file can't be used as is
+var ErrInvalidKeyring = errors.New("invalid keyring")
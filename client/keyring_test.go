@@ -0,0 +1,128 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/client"
+
+	"gotest.tools/assert"
+)
+
+// TestKeyring ...
+func TestKeyring(t *testing.T) {
+	tmp := t.TempDir()
+	keys := dir + "crypto/ecies/keys/"
+	trusteeKey, _ := os.ReadFile(keys + "trustee1.pub")
+	ownerKeyring := tmp + "/owner.json"
+	os.WriteFile(ownerKeyring, []byte(`{
+  "stakeholders": [
+    { "name": "owner1", "role": "owner", "algorithm": "ecies", "publicKeyPath": "`+keys+`owner1.pub", "secretKeyPath": "`+keys+`owner1.sk" },
+    { "name": "trustee1", "role": "trustee", "algorithm": "ecies", "publicKey": "`+string(trusteeKey)+`" },
+    { "name": "trustee2", "role": "trustee", "algorithm": "rsa", "publicKeyPath": "`+dir+`crypto/rsa/keys/trustee2.pub" }
+  ]
+}`), 0644)
+	os.Link(keys+"trustee1.pub", tmp+"/trustee1.pub")
+	os.Link(keys+"trustee1.sk", tmp+"/trustee1.sk")
+	trusteeKeyring := tmp + "/trustee.json"
+	os.WriteFile(trusteeKeyring, []byte(`{"stakeholders": [{"name": "trustee1", "role": "trustee", "algorithm": "ecies", "publicKeyPath": "trustee1.pub", "secretKeyPath": "trustee1.sk"}]}`), 0644)
+
+	keyring, err := client.LoadKeyring(ownerKeyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(keyring.Stakeholders), 3)
+	assert.Equal(t, keyring.Stakeholders[2].Name, "trustee2")
+
+	source := "cdever@edgewhere.fr"
+	crumbled, err := client.Crumble(context.Background(), client.Options{
+		Keyring: ownerKeyring,
+		Sources: []string{source},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NilError(t, crumbled[0].Err)
+
+	// Relative paths are relative to the keyring file
+	partials, err := client.Uncrumble(context.Background(), client.Options{
+		Keyring: trusteeKeyring,
+		Crumbls: []string{crumbled[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NilError(t, partials[0].Err)
+
+	// The secret key is read the same way when validating the keyring and when using it
+	sk, _ := os.ReadFile(keys + "trustee1.sk")
+	os.WriteFile(tmp+"/padded.sk", []byte("\n  "+string(sk)+"  \n\n"), 0600)
+	paddedKeyring := tmp + "/padded.json"
+	os.WriteFile(paddedKeyring, []byte(`{"stakeholders": [{"name": "trustee1", "role": "trustee", "algorithm": "ecies", "publicKeyPath": "trustee1.pub", "secretKeyPath": "padded.sk"}]}`), 0644)
+	padded, err := client.Uncrumble(context.Background(), client.Options{
+		Keyring: paddedKeyring,
+		Crumbls: []string{crumbled[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NilError(t, padded[0].Err)
+	partial2, err := client.Uncrumble(context.Background(), client.Options{
+		SignerKeys:   "rsa:" + dir + "crypto/rsa/keys/trustee2.pub",
+		SignerSecret: dir + "crypto/rsa/keys/trustee2.sk",
+		Crumbls:      []string{crumbled[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The owner's secret key is picked among several owners
+	uncrumbled, err := client.Uncrumble(context.Background(), client.Options{
+		Keyring:         ownerKeyring,
+		Crumbls:         []string{crumbled[0].Value},
+		PartialUncrumbs: []string{partials[0].Value, partial2[0].Value},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uncrumbled[0].Value, source)
+
+	// A keyring is never mixed up with the key options
+	_, err = client.Crumble(context.Background(), client.Options{
+		Keyring:   ownerKeyring,
+		OwnerKeys: "ecies:" + keys + "owner1.pub",
+		Sources:   []string{source},
+	})
+	assert.ErrorContains(t, err, "a keyring excludes")
+}
+
+// TestLoadKeyring ...
+func TestLoadKeyring(t *testing.T) {
+	tmp := t.TempDir()
+	keys := dir + "crypto/ecies/keys/"
+	for name, content := range map[string]string{
+		"empty":         `{"stakeholders": []}`,
+		"unknown field": `{"stakeholders": [{"name": "owner1", "role": "owner", "algorithm": "ecies", "publicKeyPath": "` + keys + `owner1.pub", "secret": "owner1.sk"}]}`,
+		"missing name":  `{"stakeholders": [{"role": "owner", "algorithm": "ecies", "publicKeyPath": "` + keys + `owner1.pub"}]}`,
+		"duplicate name": `{"stakeholders": [{"name": "owner1", "role": "owner", "algorithm": "ecies", "publicKeyPath": "` + keys + `owner1.pub"},
+			{"name": "owner1", "role": "trustee", "algorithm": "ecies", "publicKeyPath": "` + keys + `trustee1.pub"}]}`,
+		"duplicate key": `{"stakeholders": [{"name": "owner1", "role": "owner", "algorithm": "ecies", "publicKeyPath": "` + keys + `owner1.pub"},
+			{"name": "trustee1", "role": "trustee", "algorithm": "ecies", "publicKeyPath": "` + keys + `owner1.pub"}]}`,
+		"invalid role":      `{"stakeholders": [{"name": "owner1", "role": "emitter", "algorithm": "ecies", "publicKeyPath": "` + keys + `owner1.pub"}]}`,
+		"invalid algorithm": `{"stakeholders": [{"name": "owner1", "role": "owner", "algorithm": "dsa", "publicKeyPath": "` + keys + `owner1.pub"}]}`,
+		"missing key":       `{"stakeholders": [{"name": "owner1", "role": "owner", "algorithm": "ecies"}]}`,
+		"both keys":         `{"stakeholders": [{"name": "owner1", "role": "owner", "algorithm": "ecies", "publicKey": "04", "publicKeyPath": "` + keys + `owner1.pub"}]}`,
+		"wrong path":        `{"stakeholders": [{"name": "owner1", "role": "owner", "algorithm": "ecies", "publicKeyPath": "wrong/path.pub"}]}`,
+		"invalid key":       `{"stakeholders": [{"name": "owner1", "role": "owner", "algorithm": "ecies", "publicKey": "not-a-key"}]}`,
+		"wrong secret":      `{"stakeholders": [{"name": "owner1", "role": "owner", "algorithm": "ecies", "publicKeyPath": "` + keys + `owner1.pub", "secretKeyPath": "` + keys + `trustee1.sk"}]}`,
+		"two secrets": `{"stakeholders": [{"name": "owner1", "role": "owner", "algorithm": "ecies", "publicKeyPath": "` + keys + `owner1.pub", "secretKeyPath": "` + keys + `owner1.sk"},
+			{"name": "signer", "role": "owner", "algorithm": "ecies", "publicKeyPath": "` + keys + `signer.pub", "secretKeyPath": "` + keys + `signer.sk"}]}`,
+	} {
+		path := tmp + "/keyring.json"
+		os.WriteFile(path, []byte(content), 0644)
+		_, err := client.LoadKeyring(path)
+		assert.Assert(t, errors.Is(err, client.ErrInvalidKeyring), "%s: %v", name, err)
+	}
+}
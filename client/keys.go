@@ -0,0 +1,342 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/decrypter"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+	"github.com/cyrildever/crumbl-exe/obfuscator"
+)
+
+//--- TYPES
+
+// Keys holds the keys of some options once read and checked, eg. by a long-running service, so that they're not read again on every call.
+// Set in the options, they replace their owner, signer, emitter and obfuscation keys, along with their keyring (see LoadKeys).
+type Keys struct {
+	keys            keySet
+	emitter         signer.Signer
+	emitters        []signer.Signer
+	obfuscationKeys []obfuscator.Key
+}
+
+// logger writes diagnostic messages to the underlying writer, if any
+type logger struct {
+	w io.Writer
+}
+
+//--- METHODS
+
+func (l logger) error(err error) {
+	if l.w != nil && err != nil {
+		fmt.Fprintf(l.w, "ERROR - %v\n", err)
+	}
+}
+
+func (l logger) warning(msg string) {
+	if l.w != nil && msg != "" {
+		fmt.Fprintf(l.w, "WARNING - %v\n", msg)
+	}
+}
+
+func (l logger) success(msg string) {
+	if l.w != nil && msg != "" {
+		fmt.Fprintf(l.w, "SUCCESS - %v\n", msg)
+	}
+}
+
+//--- FUNCTIONS
+
+// LoadKeys reads and checks once all the keys of the passed options, failing on any invalid one instead of reporting it as a warning.
+// The private keys of the owner and the trusted signer, if passed, must match a single public key of their role.
+func LoadKeys(opts Options) (*Keys, error) {
+	// Any warning about a key makes it fail
+	var warnings strings.Builder
+	log := logger{&warnings}
+	opts.Keys = nil
+	keys, err := buildKeySet(opts, log)
+	if err != nil {
+		return nil, err
+	}
+	if keys.ownerSecret != "" {
+		owner, _, e := buildUser(keys.ownerOnly(), log)
+		if e != nil {
+			return nil, e
+		}
+		keys.owner = &owner
+	}
+	if keys.signerSecret != "" {
+		trustee, _, e := buildUser(keys.signerOnly(), log)
+		if e != nil {
+			return nil, e
+		}
+		keys.trustee = &trustee
+	}
+	loaded := &Keys{keys: keys}
+	if loaded.emitter, err = buildEmitter(opts, log); err != nil {
+		return nil, err
+	}
+	if loaded.emitters, err = buildEmitters(opts, log); err != nil {
+		return nil, err
+	}
+	if loaded.obfuscationKeys, err = buildObfuscationKeys(opts); err != nil {
+		return nil, err
+	}
+	if warnings.Len() > 0 {
+		warning := strings.SplitN(strings.TrimPrefix(warnings.String(), "WARNING - "), "\n", 2)[0]
+		return nil, errors.New("invalid keys: " + warning)
+	}
+	return loaded, nil
+}
+
+// buildSigners returns the signers of the passed public keys in the same order, the invalid ones being reported as warnings
+func buildSigners(keys []publicKey, log logger) []signer.Signer {
+	signers := make([]signer.Signer, 0)
+	for _, pk := range keys {
+		pubkey, err := crypto.GetKeyBytes(pk.key, pk.algorithm)
+		if err != nil {
+			log.warning(err.Error())
+			continue
+		}
+		signer := signer.Signer{
+			EncryptionAlgorithm: pk.algorithm,
+			PublicKey:           pubkey,
+		}
+		signers = append(signers, signer)
+	}
+	return signers
+}
+
+// buildEmitter returns the emitter signing the crumbls to create if its private key is passed in the options, an empty signer otherwise
+func buildEmitter(opts Options, log logger) (emitter signer.Signer, err error) {
+	if opts.Keys != nil {
+		return opts.Keys.emitter, nil
+	}
+	if opts.EmitterSecret == "" {
+		return
+	}
+	emitterKeys, err := readPublicKeys(opts.EmitterKeys, log)
+	if err != nil {
+		return
+	}
+	if len(emitterKeys) != 1 {
+		err = errors.New("invalid keys: a single public key is expected for the emitter")
+		return
+	}
+	return buildSecretSigner(emitterKeys[0], opts.EmitterSecret)
+}
+
+// buildEmitters returns the authorized emitters whose public keys are passed in the options, failing if none is valid
+// so that a misspelled key never silently disables the check of the signatures
+func buildEmitters(opts Options, log logger) (emitters []signer.Signer, err error) {
+	if opts.Keys != nil {
+		return opts.Keys.emitters, nil
+	}
+	if opts.EmitterKeys == "" {
+		return
+	}
+	emitterKeys, err := readPublicKeys(opts.EmitterKeys, log)
+	if err != nil {
+		return
+	}
+	emitters = buildSigners(emitterKeys, log)
+	if len(emitters) == 0 {
+		err = errMissingEmitterKeys
+	}
+	return
+}
+
+// buildObfuscationKeys returns the obfuscation keys whose files are passed in the options, failing on any unreadable or invalid one
+// so that a crumbl is never obfuscated with another key than the intended one
+func buildObfuscationKeys(opts Options) (keys []obfuscator.Key, err error) {
+	if opts.Keys != nil {
+		return opts.Keys.obfuscationKeys, nil
+	}
+	if opts.ObfuscationKeys == "" {
+		return
+	}
+	for _, path := range strings.Split(opts.ObfuscationKeys, ",") {
+		content, e := os.ReadFile(strings.TrimSpace(path))
+		if e != nil {
+			return nil, e
+		}
+		key, e := obfuscator.ParseKey(string(content))
+		if e != nil {
+			return nil, fmt.Errorf("%w in %s", e, path)
+		}
+		keys = append(keys, key)
+	}
+	return
+}
+
+// buildUser returns the owner whose private key is passed, or else the trusted signer whose private key is passed,
+// the invalid keys being reported as warnings unless they come from a keyring
+func buildUser(keys keySet, log logger) (user signer.Signer, isOwner bool, err error) {
+	if keys.owner != nil {
+		return *keys.owner, true, nil
+	}
+	if keys.ownerSecret != "" && (keys.strict || fileExists(keys.ownerSecret)) {
+		pk := keys.ownerKey
+		if pk.key == "" {
+			if len(keys.owners) != 1 {
+				err = errors.New("too many public keys for a data owner")
+				return
+			}
+			pk = keys.owners[0]
+		}
+		if user, err = buildSecretSigner(pk, keys.ownerSecret); err == nil || keys.strict {
+			isOwner = err == nil
+			return
+		}
+		log.warning(err.Error())
+	}
+	if keys.trustee != nil {
+		return *keys.trustee, false, nil
+	}
+	if keys.signerSecret != "" && (keys.strict || fileExists(keys.signerSecret)) {
+		pk := keys.signerKey
+		if pk.key == "" {
+			if len(keys.signers) != 1 {
+				err = errors.New("too many public keys for a single uncrumbler")
+				return
+			}
+			pk = keys.signers[0]
+		}
+		if user, err = buildSecretSigner(pk, keys.signerSecret); err == nil || keys.strict {
+			return
+		}
+		log.warning(err.Error())
+	}
+	user = signer.Signer{}
+	err = ErrNoSigner
+	return
+}
+
+// buildSecretSigner returns the signer of the passed public key along with the private key read from the passed file
+func buildSecretSigner(pk publicKey, secretPath string) (s signer.Signer, err error) {
+	pubkey, err := crypto.GetKeyBytes(pk.key, pk.algorithm)
+	if err != nil {
+		return
+	}
+	privkey, err := readSecretKey(secretPath, pk.algorithm)
+	if err != nil {
+		return
+	}
+	s = signer.Signer{
+		EncryptionAlgorithm: pk.algorithm,
+		PublicKey:           pubkey,
+		PrivateKey:          privkey,
+	}
+	return
+}
+
+// readSecretKey returns the private key held in the passed file for the passed encryption algorithm, regardless of any surrounding whitespace
+func readSecretKey(path, algorithm string) ([]byte, error) {
+	sk, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.GetKeyBytes(strings.TrimSpace(string(sk)), algorithm)
+}
+
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return false
+	}
+	return !info.IsDir()
+}
+
+// readPublicKeys returns the public keys of the passed comma-separated list of colon-separated encryption algorithm prefix and filepath
+// in the same order, without duplicates, the invalid entries being reported as warnings (see Keyring for a strict alternative)
+func readPublicKeys(dataKeys string, log logger) (keys []publicKey, err error) {
+	found := make(map[string]bool)
+	for _, tuple := range strings.Split(dataKeys, ",") {
+		if tuple != "" {
+			parts := strings.SplitN(tuple, ":", 2)
+			if len(parts) != 2 {
+				log.warning("invalid key format in " + tuple)
+				continue
+			}
+			algo := parts[0]
+			path := parts[1]
+			if path != "" {
+				if fileExists(path) {
+					content, e := os.ReadFile(path)
+					if e != nil {
+						return nil, e
+					}
+					if crypto.ExistsAlgorithm(algo) {
+						key := strings.Trim(string(content), "\n")
+						if !found[key] {
+							found[key] = true
+							keys = append(keys, publicKey{algorithm: algo, key: key})
+						}
+					} else {
+						log.warning("invalid encryption algorithm in " + tuple)
+					}
+				} else {
+					log.warning("invalid file path in " + tuple)
+				}
+			}
+		}
+	}
+	return
+}
+
+// groupUncrumbs returns the passed partial uncrumbs by verification hash, so that each crumbl of a stream only parses its own
+func groupUncrumbs(partialUncrumbs []string) map[string][]string {
+	groups := make(map[string][]string)
+	for _, u := range partialUncrumbs {
+		if vhLength := strings.Index(u, decrypter.PARTIAL_PREFIX); vhLength > 0 {
+			groups[u[:vhLength]] = append(groups[u[:vhLength]], u)
+		}
+	}
+	return groups
+}
+
+// parseUncrumbs returns the uncrumbs found in the passed partial uncrumbs matching the verification hash and the version of the passed crumbl.
+// If any signer is passed, only the partial uncrumbs signed by one of them as the recipient of their crumbs are kept,
+// the other ones being reported as warnings, unless the version of the crumbl doesn't sign its partial uncrumbs.
+func parseUncrumbs(partialUncrumbs []string, verificationHash string, crumbled core.Crumbled, signers []signer.Signer, log logger) (uncrumbs []decrypter.Uncrumb) {
+	for _, u := range partialUncrumbs {
+		// The length of the verification hash depends on the hash engine of the crumbl
+		if !strings.HasPrefix(u, verificationHash+decrypter.PARTIAL_PREFIX) {
+			continue
+		}
+		parsed, err := core.ParsePartialUncrumbs(u)
+		if err != nil {
+			log.warning(fmt.Sprintf("invalid uncrumb: %s: %v", u, err))
+			continue
+		}
+		// Otherwise, relabelling signed partial uncrumbs with an older version would strip their signature
+		if parsed.Version != crumbled.Version {
+			log.warning(fmt.Sprintf("rejected uncrumb: %s: version %s of a crumbl in version %s", u, parsed.Version, crumbled.Version))
+			continue
+		}
+		if len(signers) > 0 && !parsed.Signable() {
+			log.warning(fmt.Sprintf("partial uncrumbs not authenticated: unsigned in version %s: %s", parsed.Version, u))
+		} else if len(signers) > 0 {
+			if err = parsed.Verify(signers, crumbled.Crumbs); err != nil {
+				log.warning(fmt.Sprintf("rejected uncrumb: %s: %v", u, err))
+				continue
+			}
+		}
+		uncrumbs = append(uncrumbs, parsed.Uncrumbs...)
+	}
+	return
+}
+
+//--- ERRORS
+
+var (
+	// ErrNoSigner is returned when no private key could be associated with the passed public keys of an owner or a trusted signer
+	ErrNoSigner = errors.New("invalid keys: no signer was detected")
+
+	errMissingEmitterKeys = errors.New("missing public keys for authorized emitters")
+)
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cyrildever/crumbl-exe/mailbox"
+)
+
+//--- FUNCTIONS
+
+// CollectFromMailbox posts an uncrumble request for the crumbls in the options to the mailbox at the passed path, unless already posted,
+// and returns the partial uncrumbs of the responses signed by the trustees in the options along with the ID of the request.
+// Responses from unknown trustees or with an invalid signature are ignored, and reported to the diagnostics writer of the options.
+func CollectFromMailbox(opts Options, path string) (partialUncrumbs []string, requestID string, err error) {
+	log := logger{opts.Diagnostics}
+	keys, err := buildKeySet(opts, log)
+	if err != nil {
+		return
+	}
+	if len(keys.signers) == 0 {
+		err = errors.New("missing public keys for trusted signers")
+		return
+	}
+	m := mailbox.Mailbox{Path: path}
+	req, err := m.Post(opts.Crumbls)
+	if err != nil {
+		return
+	}
+	requestID = req.ID
+	responses, invalid, err := m.Responses(req.ID, buildSigners(keys.signers, log))
+	if err != nil {
+		return
+	}
+	for _, e := range invalid {
+		log.warning(e.Error())
+	}
+	for _, res := range responses {
+		for _, partial := range res.PartialUncrumbs {
+			if partial != "" {
+				partialUncrumbs = append(partialUncrumbs, partial)
+			}
+		}
+	}
+	return
+}
+
+// AnswerMailbox answers the pending requests of the mailbox at the passed path as the trusted signer of the options and returns the number of answered requests.
+// If the interval is strictly positive, it rather keeps on answering the new requests every interval until the context is done.
+func AnswerMailbox(ctx context.Context, opts Options, path string, interval time.Duration) (answered int, err error) {
+	log := logger{opts.Diagnostics}
+	keys, err := buildKeySet(opts, log)
+	if err != nil {
+		return
+	}
+	trustee, _, err := buildUser(keys.signerOnly(), log)
+	if err != nil {
+		return
+	}
+	m := mailbox.Mailbox{Path: path}
+	if interval > 0 {
+		m.Watch(ctx, trustee, interval, opts.Diagnostics)
+		return
+	}
+	return m.Answer(trustee, opts.Diagnostics)
+}
@@ -1,24 +1,27 @@
 package client
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
-	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
-	"runtime"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/cyrildever/crumbl-exe/core"
-	"github.com/cyrildever/crumbl-exe/crypto"
-	"github.com/cyrildever/crumbl-exe/decrypter"
-	"github.com/cyrildever/crumbl-exe/models/signer"
 	"github.com/cyrildever/crumbl-exe/utils"
 )
 
 //--- TYPES
 
-// CrumblWorker ...
+// CrumblWorker mimics the executable: it reads its data from the arguments and/or the input file,
+// and writes the results either to the output file or to stdout, status and diagnostic messages going to stderr.
+// It never exits the process, leaving it to the caller.
 type CrumblWorker struct {
 	Mode             CrumblMode
 	Input            string
@@ -27,8 +30,28 @@ type CrumblWorker struct {
 	OwnerSecret      string
 	SignerKeys       string
 	SignerSecret     string
+	Keyring          string // Optional: the filepath to a keyring replacing OwnerKeys, OwnerSecret, SignerKeys and SignerSecret (see Keyring)
 	VerificationHash string
 	Data             []string
+	Batch            bool
+	Workers          int
+	Redundancy       int
+	Threshold        int
+	HashEngine       string
+	EmitterKeys      string        // Optional: the public keys of the authorized emitters when extracting or inspecting, or of the single emitter along with EmitterSecret
+	EmitterSecret    string        // Optional: the filepath to the private key of the emitter signing the crumbls when creating or rekeying
+	NewOwnerKeys     string        // Optional: the public keys of the owners of the rekeyed crumbls, defaults to OwnerKeys, or of the owners to add when delegating
+	NewSignerKeys    string        // Optional: the public keys of the trusted signers of the rekeyed crumbls, defaults to SignerKeys
+	ObfuscationKeys  string        // Optional: the filepaths to the obfuscation keys, the first one being used when creating or rekeying
+	Format           Format        // Output format: text, binary or json when the results are crumbls, text or json otherwise
+	CSVColumns       string        // Optional: the comma-separated names or positions of the columns to process, the input then being a CSV file (see CSVOptions)
+	CSVNoHeader      bool          // Set to `true` if the CSV file has no header
+	JSONPaths        string        // Optional: the comma-separated JSON path selectors of the string fields to process, the input then being JSON documents (see JSONOptions)
+	Mailbox          string        // Optional: the path to the mailbox directory to exchange the partial uncrumbs with the trustees when extracting
+	Poll             time.Duration // Optional: the interval between two checks of the mailbox when answering it as a trusted signer, only checked once if not strictly positive
+	Stdin            io.Reader     // Optional: the reader to use when Input is STDIO, defaults to os.Stdin
+	Stdout           io.Writer     // Optional: the writer to use when Output is empty or STDIO, defaults to os.Stdout
+	Diagnostics      io.Writer     // Optional: the writer of the status, warning and error messages, defaults to os.Stderr
 }
 
 // CrumblMode ...
@@ -37,347 +60,703 @@ type CrumblMode string
 const (
 	CREATION   CrumblMode = "crumbl"
 	EXTRACTION CrumblMode = "uncrumbl"
+	INSPECTION CrumblMode = "inspect"
+	REKEYING   CrumblMode = "rekey"
+	DELEGATION CrumblMode = "delegate"
+	REVOCATION CrumblMode = "revoke"
 )
 
+// STDIO is the path to pass as Input or Output to use stdin or stdout
+const STDIO = "-"
+
 //--- METHODS
 
-// Process ...
+// Process executes the worker's operation and writes its result.
+// If returnResult is set to `true`, the result is also returned.
 func (w *CrumblWorker) Process(returnResult bool) (result string, err error) {
-	// Prepare processing...
+	log := logger{w.diagnostics()}
 
 	// Check mode
-	if w.Mode != CREATION && w.Mode != EXTRACTION {
+	if w.Mode != CREATION && w.Mode != EXTRACTION && w.Mode != INSPECTION && w.Mode != REKEYING && w.Mode != DELEGATION && w.Mode != REVOCATION {
 		err = fmt.Errorf("invalid mode: %s", w.Mode)
-		if !Check(err, returnResult) {
+		return
+	}
+	if w.Format != "" && w.Format != TEXT_FORMAT && w.Format != JSON_FORMAT && (!w.createsCrumbls() || w.Format != BINARY_FORMAT) {
+		err = fmt.Errorf("invalid format: %s", w.Format)
+		return
+	}
+
+	if w.Mailbox != "" && w.Mode != EXTRACTION {
+		log.warning("mailbox is only used when extracting")
+	}
+	if w.Mailbox != "" && w.Mode == EXTRACTION {
+		isOwner, e := w.isOwner()
+		if e != nil {
+			err = e
 			return
 		}
+		if !isOwner {
+			return w.answerMailbox(returnResult, log)
+		}
+	}
+	if w.CSVColumns != "" || w.JSONPaths != "" {
+		return w.processFields(returnResult, log)
 	}
 
-	// Build data if need be
-	if len(w.Data) == 0 {
+	// Build data
+	var stdin *bufio.Reader
+	if w.Input == STDIO {
+		stdin = bufio.NewReader(w.stdin())
+		if w.Batch && w.Mailbox == "" && !w.isBinaryInput(stdin) {
+			// Each line is processed as soon as it's read
+			return w.stream(stdin, returnResult, log)
+		}
+	}
+	if w.Batch {
+		// In batch mode, each line of the input file is processed independently
 		if w.Input == "" {
-			err = errors.New("invalid data: not enough arguments and/or no input file to use")
-			if !Check(err, returnResult) {
-				return
-			}
+			err = errors.New("invalid data: batch mode requires an input file")
+			return
+		}
+		if len(w.Data) != 0 {
+			log.warning("arguments are ignored in batch mode: only the input file is used")
+		}
+		if w.VerificationHash != "" {
+			log.warning("verification hash is ignored in batch mode: each crumbl provides its own")
+		}
+		content, isBinary, e := w.readInput(stdin)
+		if e != nil {
+			err = e
+			return
+		}
+		if isBinary {
+			w.Data = content
 		} else {
-			content, e := ioutil.ReadFile(w.Input)
-			if !Check(e, returnResult) {
-				err = e
-				return
-			}
-			// TODO Add multiple-line handling (using one crumbl per line in input file)
-			contentStr := strings.Replace(string(content), "\n", " ", -1)
-			w.Data = utils.RegexSplit(contentStr, "\\s+")
+			w.Data = readLines(content[0])
 		}
-	} else {
+	} else if w.Input != "" {
 		// Any data in an input file should be prepended to the data from the command-line arguments
-		if w.Input != "" {
-			// In this case where there are arguments and an input file, there's no possible multiline handling
-			tmp := w.Data
-			content, e := ioutil.ReadFile(w.Input)
-			if !Check(e, returnResult) {
-				err = e
-				return
-			}
-			contentStr := strings.Replace(string(content), "\n", " ", -1)
-			w.Data = utils.RegexSplit(contentStr, "\\s+")
-			w.Data = append(w.Data, tmp...)
+		content, isBinary, e := w.readInput(stdin)
+		if e != nil {
+			err = e
+			return
 		}
-	}
-
-	// Get algorithm and keys
-	ownersMap, err := fillMap(w.OwnerKeys, returnResult)
-	if !Check(err, returnResult) {
+		if isBinary {
+			w.Data = append(content, w.Data...)
+		} else {
+			contentStr := strings.Replace(content[0], "\n", " ", -1)
+			w.Data = append(utils.RegexSplit(strings.TrimSpace(contentStr), "\\s+"), w.Data...)
+		}
+	} else if len(w.Data) == 0 {
+		err = errors.New("invalid data: not enough arguments and/or no input file to use")
 		return
 	}
-	signersMap, err := fillMap(w.SignerKeys, returnResult)
-	if !Check(err, returnResult) {
+	if len(w.Data) == 0 {
+		err = errors.New("no data to use")
 		return
 	}
-	if len(ownersMap) == 0 && (w.Mode == CREATION || (w.Mode == EXTRACTION && len(signersMap) == 0)) {
-		err = errors.New("missing public key for the data owner")
-		if !Check(err, returnResult) {
-			return
+
+	// Do processing...
+	opts := w.options()
+	var lines []int
+	var results []Result
+	if w.Batch {
+		opts.VerificationHash = ""
+		for i, line := range w.Data {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			lines = append(lines, i)
+			if w.Mode == CREATION {
+				opts.Sources = append(opts.Sources, line)
+			} else {
+				data := fromJSON(utils.RegexSplit(strings.TrimSpace(line), "\\s+"), log)
+				opts.Crumbls = append(opts.Crumbls, data[0])
+				opts.PartialUncrumbs = append(opts.PartialUncrumbs, data[1:]...)
+			}
+		}
+	} else {
+		lines = []int{0}
+		if w.Mode == CREATION {
+			opts.Sources = w.Data[:1]
+		} else {
+			data := fromJSON(w.Data, log)
+			opts.Crumbls = data[:1]
+			opts.PartialUncrumbs = data[1:]
 		}
 	}
-	if len(signersMap) == 0 && (w.Mode == CREATION || (w.Mode == EXTRACTION && len(ownersMap) == 0)) {
-		err = errors.New("missing public keys for trusted signers")
-		if !Check(err, returnResult) {
+	if w.Mailbox != "" && w.Mode == EXTRACTION {
+		partialUncrumbs, requestID, e := CollectFromMailbox(opts, w.Mailbox)
+		if e != nil {
+			err = e
 			return
 		}
+		if len(partialUncrumbs) == 0 {
+			log.warning("no response from trustees yet in mailbox for request " + requestID)
+		}
+		opts.PartialUncrumbs = append(opts.PartialUncrumbs, partialUncrumbs...)
 	}
-
-	// Check data
-	if w.Mode == EXTRACTION && w.VerificationHash == "" {
-		logWarning("verification hash is missing")
+	switch w.Mode {
+	case CREATION:
+		results, err = Crumble(context.Background(), opts)
+	case INSPECTION:
+		results, err = w.inspect(opts)
+	case REKEYING:
+		results, err = Rekey(context.Background(), opts)
+	case DELEGATION:
+		results, err = AddOwner(context.Background(), opts)
+	case REVOCATION:
+		results, err = RevokeOwner(context.Background(), opts)
+	default:
+		results, err = Uncrumble(context.Background(), opts)
 	}
-	if len(w.Data) == 0 {
-		err = errors.New("no data to use")
-		if !Check(err, returnResult) {
-			return
+	if err != nil {
+		return
+	}
+	for i := range results {
+		results[i].Index = lines[results[i].Index]
+		if results[i].Err == nil {
+			results[i].Value, results[i].Err = w.format(results[i].Value)
 		}
 	}
 
-	// Do processing...
-	if w.Mode == CREATION {
-		owners := buildSigners(ownersMap)
-		trustees := buildSigners(signersMap)
-		return w.create(owners, trustees, returnResult)
+	if w.Batch {
+		return w.writeBatch(results, returnResult, log)
 	}
-	if w.Mode == EXTRACTION {
-		user, isOwner, e := w.buildUser(ownersMap, signersMap, returnResult)
-		if !Check(e, returnResult) {
-			err = e
-			return
+	if results[0].Err != nil {
+		err = results[0].Err
+		return
+	}
+	err = w.write(results[0].Value)
+	if err == nil && returnResult {
+		result = results[0].Value
+	}
+	return
+}
+
+// options returns the options of the worker
+func (w *CrumblWorker) options() Options {
+	return Options{
+		OwnerKeys:        w.OwnerKeys,
+		OwnerSecret:      w.OwnerSecret,
+		SignerKeys:       w.SignerKeys,
+		SignerSecret:     w.SignerSecret,
+		Keyring:          w.Keyring,
+		VerificationHash: w.VerificationHash,
+		Workers:          w.Workers,
+		Redundancy:       w.Redundancy,
+		Threshold:        w.Threshold,
+		HashEngine:       w.HashEngine,
+		EmitterKeys:      w.EmitterKeys,
+		EmitterSecret:    w.EmitterSecret,
+		NewOwnerKeys:     w.NewOwnerKeys,
+		NewSignerKeys:    w.NewSignerKeys,
+		ObfuscationKeys:  w.ObfuscationKeys,
+		Diagnostics:      w.diagnostics(),
+	}
+}
+
+// isOwner tells whether the private key of an owner is passed to the worker, either directly or in its keyring
+func (w *CrumblWorker) isOwner() (bool, error) {
+	if w.Keyring == "" {
+		return w.OwnerSecret != "", nil
+	}
+	keyring, err := LoadKeyring(w.Keyring)
+	if err != nil {
+		return false, err
+	}
+	for _, s := range keyring.Stakeholders {
+		if s.Role == OWNER_ROLE && s.SecretKeyPath != "" {
+			return true, nil
 		}
-		return w.extract(user, isOwner, returnResult)
+	}
+	return false, nil
+}
+
+// answerMailbox answers the requests of the mailbox as a trusted signer, until interrupted if the worker polls it
+func (w *CrumblWorker) answerMailbox(returnResult bool, log logger) (result string, err error) {
+	if len(w.Data) != 0 || w.Input != "" {
+		log.warning("data is ignored when answering a mailbox: only its requests are used")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	answered, err := AnswerMailbox(ctx, w.options(), w.Mailbox, w.Poll)
+	if err != nil || w.Poll > 0 {
+		return
+	}
+	result = fmt.Sprintf("%d request(s) answered in %v", answered, w.Mailbox)
+	log.success(result)
+	if !returnResult {
+		result = ""
 	}
 	return
 }
 
-func (w *CrumblWorker) create(owners []signer.Signer, trustees []signer.Signer, returnResult bool) (result string, err error) {
-	crumbl := core.Crumbl{
-		Source:     w.Data[0],
-		HashEngine: crypto.DEFAULT_HASH_ENGINE,
-		Owners:     owners,
-		Trustees:   trustees,
+// processFields crumbles or uncrumbles the selected columns of the input CSV file or the selected fields of the input JSON documents,
+// the data arguments being the partial uncrumbs when extracting
+func (w *CrumblWorker) processFields(returnResult bool, log logger) (result string, err error) {
+	kind := "CSV"
+	if w.JSONPaths != "" {
+		kind = "JSON"
 	}
-	if w.Output == "" {
-		res, e := crumbl.ToStdOut()
-		if !Check(e, returnResult) {
+	if w.CSVColumns != "" && w.JSONPaths != "" {
+		err = errors.New("invalid flags: CSV columns and JSON paths can't be used together")
+		return
+	}
+	if w.Mode != CREATION && w.Mode != EXTRACTION {
+		err = fmt.Errorf("invalid mode: %s fields can only be crumbled or extracted", kind)
+		return
+	}
+	if w.Format != "" && w.Format != TEXT_FORMAT {
+		err = fmt.Errorf("invalid format: %s", w.Format)
+		return
+	}
+	if w.Input == "" {
+		err = fmt.Errorf("invalid data: %s mode requires an input file", kind)
+		return
+	}
+	if w.Batch {
+		log.warning(fmt.Sprintf("batch mode is ignored in %s mode: each record is processed independently anyway", kind))
+	}
+	if w.Mode == CREATION && len(w.Data) != 0 {
+		log.warning(fmt.Sprintf("arguments are ignored when crumbling in %s mode: only the input file is used", kind))
+	}
+	if w.VerificationHash != "" {
+		log.warning(fmt.Sprintf("verification hash is ignored in %s mode: each crumbl provides its own", kind))
+	}
+
+	var input io.Reader = w.stdin()
+	if w.Input != STDIO {
+		f, e := os.Open(w.Input)
+		if e != nil {
 			err = e
 			return
 		}
-		if w.VerificationHash != "" && !strings.HasPrefix(res, w.VerificationHash) {
-			logWarning("verification hash is not coherent with data source'")
-		}
-		if returnResult {
-			result = res
+		defer f.Close()
+		input = f
+	}
+	var output io.Writer = w.stdout()
+	if w.Output != "" && w.Output != STDIO {
+		f, e := w.openOutput()
+		if e != nil {
+			err = e
 			return
 		}
-		os.Exit(0)
+		defer f.Close()
+		output = f
 	}
-	res, e := crumbl.ToFile(w.Output)
-	if !Check(e, returnResult) {
-		err = e
-		return
+	var written strings.Builder
+	if returnResult {
+		output = io.MultiWriter(output, &written)
 	}
-	if w.VerificationHash != "" && !strings.HasPrefix(res, w.VerificationHash) {
-		logWarning("verification hash is not coherent with data source'")
+
+	opts := w.options()
+	if w.Mode == EXTRACTION {
+		opts.PartialUncrumbs = fromJSON(w.Data, log)
 	}
-	if returnResult {
-		result = res
+	csvOpts := CSVOptions{
+		Columns:  strings.Split(w.CSVColumns, ","),
+		NoHeader: w.CSVNoHeader,
+	}
+	jsonOpts := JSONOptions{
+		Paths: strings.Split(w.JSONPaths, ","),
+	}
+	switch {
+	case kind == "JSON" && w.Mode == CREATION:
+		err = CrumbleJSON(context.Background(), opts, jsonOpts, input, output)
+	case kind == "JSON":
+		err = UncrumbleJSON(context.Background(), opts, jsonOpts, input, output)
+	case w.Mode == CREATION:
+		err = CrumbleCSV(context.Background(), opts, csvOpts, input, output)
+	default:
+		err = UncrumbleCSV(context.Background(), opts, csvOpts, input, output)
+	}
+	result = written.String()
+	if err == nil && w.Output != "" && w.Output != STDIO {
+		log.success(fmt.Sprintf("%s saved to %v", kind, w.Output))
 	}
 	return
 }
 
-func (w *CrumblWorker) extract(user signer.Signer, isOwner bool, returnResult bool) (result string, err error) {
-	// TODO Add multiple-line handling (using one crumbl per line in input file)
-	var uncrumbs []decrypter.Uncrumb
-	minLength := len(core.VERSION) + 1 + crypto.DEFAULT_HASH_LENGTH + len(decrypter.PARTIAL_PREFIX) + 1
-	for _, u := range w.Data[1:] {
-		if len(u) > minLength {
-			parts := strings.SplitN(u, ".", 2)
-			if parts[1] != core.VERSION {
-				logWarning("wrong version for uncrumb: " + u)
-				continue
-			}
-			vh := parts[0][:crypto.DEFAULT_HASH_LENGTH]
-			if vh == w.VerificationHash {
-				us := parts[0][crypto.DEFAULT_HASH_LENGTH:]
-				uncs := strings.Split(us, decrypter.PARTIAL_PREFIX)
-				for _, unc := range uncs {
-					if unc != "" {
-						uncrumb, err := decrypter.ToUncrumb(unc)
-						if err != nil {
-							continue
-						}
-						uncrumbs = append(uncrumbs, uncrumb)
+// stream processes each line read from the passed reader as soon as it's available,
+// writing its result in the same order and the same way as in batch mode.
+func (w *CrumblWorker) stream(reader *bufio.Reader, returnResult bool, log logger) (result string, err error) {
+	opts := w.options()
+	opts.VerificationHash = ""
+
+	// A failed write tears the whole pipeline down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan string)
+	results, err := w.streamResults(ctx, opts, lines)
+	if err != nil {
+		return
+	}
+	var output io.Writer = w.stdout()
+	if w.Output != "" && w.Output != STDIO {
+		f, e := w.openOutput()
+		if e != nil {
+			err = e
+			return
+		}
+		defer f.Close()
+		output = f
+	}
+
+	// Tells for each line read whether it's empty, in the same order
+	empties := make(chan bool, 1024)
+	var readErr error
+	go func() {
+		defer close(lines)
+		defer close(empties)
+		for {
+			line, e := reader.ReadString('\n')
+			if line != "" || e == nil {
+				line = strings.TrimRight(line, "\r\n")
+				empty := strings.TrimSpace(line) == ""
+				select {
+				case empties <- empty:
+				case <-ctx.Done():
+					return
+				}
+				if !empty {
+					select {
+					case lines <- w.prepare(line, log):
+					case <-ctx.Done():
+						return
 					}
 				}
-			} else {
-				logWarning("incompatible verification hash: " + vh)
-				continue
+			}
+			if e != nil {
+				if e != io.EOF {
+					readErr = e
+				}
+				return
 			}
 		}
-	}
+	}()
 
-	uncrumbl := core.Uncrumbl{
-		Crumbled:         w.Data[0],
-		Slices:           uncrumbs,
-		VerificationHash: w.VerificationHash,
-		Signer:           user,
-		IsOwner:          isOwner,
-	}
-	if w.Output == "" {
-		res, e := uncrumbl.ToStdOut()
-		if !Check(e, returnResult) {
-			err = e
+	var written strings.Builder
+	index, successes, failures := 0, 0, 0
+	for empty := range empties {
+		index++
+		value := ""
+		if !empty {
+			res := <-results
+			if res.Err == nil {
+				value, res.Err = w.format(res.Value)
+			}
+			if res.Err != nil {
+				log.error(fmt.Errorf("line %d: %w", index, res.Err))
+				value = ""
+				failures++
+			} else {
+				successes++
+			}
+		}
+		if !w.isBinary() {
+			value += "\n"
+		}
+		if _, err = io.WriteString(output, value); err != nil {
 			return
 		}
 		if returnResult {
-			result = res
-			return
+			written.WriteString(value)
 		}
-		os.Exit(0)
 	}
-	res, e := uncrumbl.ToFile(w.Output)
-	if !Check(e, returnResult) {
-		err = e
+	if readErr != nil {
+		err = readErr
 		return
 	}
-	if returnResult {
-		result = res
+	result = written.String()
+	if !w.isBinary() {
+		result = strings.TrimSuffix(result, "\n")
+	}
+	if w.Output != "" && w.Output != STDIO {
+		log.success(fmt.Sprintf("%d result(s) saved to %v", successes, w.Output))
+	}
+	if failures > 0 {
+		err = fmt.Errorf("%d of %d line(s) failed", failures, successes+failures)
 	}
 	return
 }
 
-//--- FUNCTIONS
+// streamResults processes the passed lines according to the mode of the worker until the passed context is done
+func (w *CrumblWorker) streamResults(ctx context.Context, opts Options, lines <-chan string) (<-chan Result, error) {
+	switch w.Mode {
+	case CREATION:
+		return CrumbleStream(ctx, opts, lines)
+	case EXTRACTION:
+		return UncrumbleStream(ctx, opts, lines)
+	case REKEYING:
+		return RekeyStream(ctx, opts, lines)
+	case DELEGATION:
+		return AddOwnerStream(ctx, opts, lines)
+	case REVOCATION:
+		return RevokeOwnerStream(ctx, opts, lines)
+	}
+	if _, err := w.inspect(opts); err != nil {
+		return nil, err
+	}
+	// A malformed line only fails its own result
+	return processStream(ctx, lines, func(crumbled string) (string, error) {
+		opts.Crumbls = []string{crumbled}
+		res, err := w.inspect(opts)
+		if err != nil {
+			return "", err
+		}
+		if len(res) != 1 {
+			return "", errors.New("invalid crumbl: " + crumbled)
+		}
+		return res[0].Value, res[0].Err
+	}), nil
+}
 
-// Check ...
-func Check(e error, returnResult bool) bool {
-	if e != nil {
-		if returnResult {
-			return false
+// inspect describes the crumbls of the passed options, each one failing unless signed by one of their emitters if any
+func (w *CrumblWorker) inspect(opts Options) (results []Result, err error) {
+	results, err = Inspect(opts.Crumbls, w.Format)
+	if err != nil || opts.EmitterKeys == "" {
+		return
+	}
+	authentic, err := Authenticate(opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, res := range authentic {
+		if res.Err != nil {
+			results[i] = res
 		}
-		_, fn, line, _ := runtime.Caller(1)
-		fmt.Fprintf(os.Stderr, "ERROR - %v [%s:%d]\n", e, fn, line)
-		flag.Usage()
-		os.Exit(1)
 	}
-	return true
+	return
+}
+
+// prepare returns the passed line ready to be streamed, ie. with any JSON item converted to its text form when extracting or inspecting
+func (w *CrumblWorker) prepare(line string, log logger) string {
+	if w.Mode == CREATION {
+		return line
+	}
+	return strings.Join(fromJSON(utils.RegexSplit(strings.TrimSpace(line), "\\s+"), log), " ")
+}
+
+// isBinaryInput tells whether the passed reader starts with binary crumbls to extract or inspect
+func (w *CrumblWorker) isBinaryInput(reader *bufio.Reader) bool {
+	if w.Mode == CREATION {
+		return false
+	}
+	start, _ := reader.Peek(len(core.BINARY_MAGIC))
+	return core.IsBinary(start)
+}
+
+func (w *CrumblWorker) stdin() io.Reader {
+	if w.Stdin == nil {
+		return os.Stdin
+	}
+	return w.Stdin
+}
+
+func (w *CrumblWorker) stdout() io.Writer {
+	if w.Stdout == nil {
+		return os.Stdout
+	}
+	return w.Stdout
+}
+
+func (w *CrumblWorker) diagnostics() io.Writer {
+	if w.Diagnostics == nil {
+		return os.Stderr
+	}
+	return w.Diagnostics
+}
+
+// readInput returns the content of the input file (or of the passed stdin), or the text form of each crumbl it holds
+// when extracting or inspecting binary crumbls
+func (w *CrumblWorker) readInput(stdin *bufio.Reader) (content []string, isBinary bool, err error) {
+	var data []byte
+	if stdin != nil {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(w.Input)
+	}
+	if err != nil {
+		return
+	}
+	if w.Mode == CREATION || !core.IsBinary(data) {
+		return []string{string(data)}, false, nil
+	}
+	crumbls, err := core.ParseBinaries(data)
+	if err != nil {
+		return
+	}
+	for _, crumbled := range crumbls {
+		content = append(content, crumbled.String())
+	}
+	return content, true, nil
+}
+
+// createsCrumbls tells whether the results are crumbls, ie. in any mode but extracting or inspecting
+func (w *CrumblWorker) createsCrumbls() bool {
+	return w.Mode != EXTRACTION && w.Mode != INSPECTION
+}
+
+// isBinary tells whether the results are binary crumbls, which are written as is without any separator
+func (w *CrumblWorker) isBinary() bool {
+	return w.createsCrumbls() && w.Format == BINARY_FORMAT
 }
 
-func buildSigners(withMap map[string]string) []signer.Signer {
-	signers := make([]signer.Signer, 0)
-	for pk, algo := range withMap {
-		pubkey, err := crypto.GetKeyBytes(pk, algo)
+// format converts the passed result from its text form to the output format
+func (w *CrumblWorker) format(result string) (string, error) {
+	switch {
+	case w.createsCrumbls() && w.Format == BINARY_FORMAT:
+		return toBinary(result)
+	case w.createsCrumbls() && w.Format == JSON_FORMAT:
+		parsed, err := core.Parse(result)
 		if err != nil {
-			logWarning(err.Error())
-			continue
+			return "", err
 		}
-		signer := signer.Signer{
-			EncryptionAlgorithm: algo,
-			PublicKey:           pubkey,
+		return toJSON(parsed)
+	case w.Mode == EXTRACTION && w.Format == JSON_FORMAT:
+		// Only partial uncrumbs are converted, a fully-deciphered source being returned as is
+		if partialUncrumbs, err := core.ParsePartialUncrumbs(result); err == nil {
+			return toJSON(partialUncrumbs)
 		}
-		signers = append(signers, signer)
 	}
-	return signers
+	return result, nil
 }
 
-func (w *CrumblWorker) buildUser(ownersMap map[string]string, signersMap map[string]string, returnResult bool) (user signer.Signer, isOwner bool, err error) {
-	var u signer.Signer
-	hasSigner := false
-	owns := false
-	if w.OwnerSecret != "" && fileExists(w.OwnerSecret) {
-		if len(ownersMap) != 1 {
-			err = errors.New("too many public keys for a data owner")
-			return
-		}
-		sk, e := ioutil.ReadFile(w.OwnerSecret)
-		if e != nil {
-			err = e
-			return
-		}
-		for pk, algo := range ownersMap {
-			pubkey, err := crypto.GetKeyBytes(pk, algo)
-			if err != nil {
-				logWarning(err.Error())
-				continue
-			}
-			privkey, err := crypto.GetKeyBytes(string(sk), algo)
-			if err != nil {
-				logWarning(err.Error())
-				continue
-			}
-			u = signer.Signer{
-				EncryptionAlgorithm: algo,
-				PublicKey:           pubkey,
-				PrivateKey:          privkey,
-			}
-			hasSigner = true
-			owns = true
-			break
+// write sends the passed result to stdout or appends it to the output file
+func (w *CrumblWorker) write(result string) error {
+	if !w.isBinary() {
+		result += "\n"
+	}
+	if w.Output == "" || w.Output == STDIO {
+		_, err := io.WriteString(w.stdout(), result)
+		return err
+	}
+	f, err := w.openOutput()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = f.WriteString(result); err != nil {
+		return err
+	}
+	logger{w.diagnostics()}.success("result saved to " + w.Output)
+	return nil
+}
+
+// openOutput opens the output file to append the results to, creating it if need be, whatever the mode of the worker
+func (w *CrumblWorker) openOutput() (*os.File, error) {
+	return os.OpenFile(w.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+// writeBatch writes the results line-aligned with the input data, appending them to the output file if any.
+// A failing line doesn't stop the process: its error is reported to the diagnostics writer and an empty line is written in its place.
+func (w *CrumblWorker) writeBatch(batch []Result, returnResult bool, log logger) (result string, err error) {
+	results := make([]string, len(w.Data))
+	successes, failures := 0, 0
+	for _, res := range batch {
+		if res.Err != nil {
+			log.error(fmt.Errorf("line %d: %w", res.Index+1, res.Err))
+			failures++
+			continue
 		}
+		results[res.Index] = res.Value
+		successes++
 	}
-	if !hasSigner && w.SignerSecret != "" && fileExists(w.SignerSecret) {
-		if len(signersMap) != 1 {
-			err = errors.New("too many public keys for a single uncrumbler")
+	output := strings.Join(results, "\n") + "\n"
+	if returnResult {
+		result = strings.Join(results, "\n")
+	}
+	if w.isBinary() {
+		// Binary crumbls are self-delimited, failures being left out
+		output = strings.Join(results, "")
+		result = output
+	}
+	if w.Output == "" || w.Output == STDIO {
+		if _, err = io.WriteString(w.stdout(), output); err != nil {
 			return
 		}
-		sk, e := ioutil.ReadFile(w.SignerSecret)
+	} else {
+		f, e := w.openOutput()
 		if e != nil {
 			err = e
 			return
 		}
-		for pk, algo := range signersMap {
-			pubkey, err := crypto.GetKeyBytes(pk, algo)
-			if err != nil {
-				logWarning(err.Error())
-				continue
-			}
-			privkey, err := crypto.GetKeyBytes(string(sk), algo)
-			if err != nil {
-				logWarning(err.Error())
-				continue
-			}
-			u = signer.Signer{
-				EncryptionAlgorithm: algo,
-				PublicKey:           pubkey,
-				PrivateKey:          privkey,
-			}
-			hasSigner = true
-			break
+		_, err = f.WriteString(output)
+		if e := f.Close(); err == nil {
+			err = e
 		}
+		if err != nil {
+			return
+		}
+		log.success(fmt.Sprintf("%d result(s) saved to %v", successes, w.Output))
 	}
-	if !hasSigner {
-		err = errors.New("invalid keys: no signer was detected")
-		return
+	if failures > 0 {
+		err = fmt.Errorf("%d of %d line(s) failed", failures, successes+failures)
 	}
-	user = u
-	isOwner = owns
 	return
 }
 
-func fileExists(filename string) bool {
-	info, err := os.Stat(filename)
-	if os.IsNotExist(err) {
-		return false
-	}
-	return !info.IsDir()
-}
+//--- FUNCTIONS
 
-func fillMap(dataKeys string, returnResult bool) (map[string]string, error) {
-	theMap := make(map[string]string)
-	for _, tuple := range strings.Split(dataKeys, ",") {
-		if tuple != "" {
-			parts := strings.SplitN(tuple, ":", 2)
-			algo := parts[0]
-			path := parts[1]
-			if path != "" {
-				if fileExists(path) {
-					key, e := ioutil.ReadFile(path)
-					if !Check(e, returnResult) {
-						return nil, e
-					}
-					if crypto.ExistsAlgorithm(algo) {
-						theMap[strings.Trim(string(key), "\n")] = algo
-					} else {
-						logWarning("invalid encryption algorithm in " + tuple)
-					}
-				} else {
-					logWarning("invalid file path in " + tuple)
+// fromJSON returns the passed items, any JSON crumbl or partial uncrumbs among them being converted to its text form.
+// An item that fails to convert is kept as is for its processing to report the failure.
+func fromJSON(items []string, log logger) []string {
+	converted := make([]string, len(items))
+	for i, item := range items {
+		converted[i] = item
+		if !strings.HasPrefix(item, "{") {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		err := json.Unmarshal([]byte(item), &fields)
+		if err == nil {
+			if _, found := fields["uncrumbs"]; found {
+				var partialUncrumbs core.PartialUncrumbs
+				if err = json.Unmarshal([]byte(item), &partialUncrumbs); err == nil {
+					converted[i] = partialUncrumbs.String()
+				}
+			} else {
+				var crumbled core.Crumbled
+				if err = json.Unmarshal([]byte(item), &crumbled); err == nil {
+					converted[i] = crumbled.String()
 				}
 			}
 		}
+		if err != nil {
+			log.warning("invalid JSON item: " + err.Error())
+		}
+	}
+	return converted
+}
+
+// toJSON returns the JSON representation of the passed crumbl or partial uncrumbs
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// toBinary returns the binary representation of the passed crumbl
+func toBinary(crumbled string) (string, error) {
+	parsed, err := core.Parse(crumbled)
+	if err != nil {
+		return "", err
+	}
+	data, err := parsed.MarshalBinary()
+	if err != nil {
+		return "", err
 	}
-	return theMap, nil
+	return string(data), nil
 }
 
-func logWarning(msg string) {
-	if msg != "" {
-		_, fn, line, _ := runtime.Caller(1)
-		fmt.Fprintf(os.Stderr, "WARNING - %v [%s:%d]\n", msg, fn, line)
+// readLines splits the passed content into lines, ignoring the trailing newline and any carriage return
+func readLines(content string) []string {
+	content = strings.TrimSuffix(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if content == "" {
+		return nil
 	}
+	return strings.Split(content, "\n")
 }
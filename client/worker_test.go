@@ -1,11 +1,14 @@
 package client_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
 	"strings"
 	"testing"
 
 	"github.com/cyrildever/crumbl-exe/client"
+	"github.com/cyrildever/crumbl-exe/core"
 
 	"gotest.tools/assert"
 )
@@ -41,3 +44,382 @@ func getHomeDirectory() (string, error) {
 	}
 	return strings.Replace(dir, "client", "", 1), nil
 }
+
+// TestWorkerBatch ...
+func TestWorkerBatch(t *testing.T) {
+	sources := []string{"cdever@edgewhere.fr", "", "contact@edgewhere.fr"}
+	tmp := t.TempDir()
+	input := tmp + "/sources.txt"
+	err := os.WriteFile(input, []byte(strings.Join(sources, "\n")+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Creation
+	creator := client.CrumblWorker{
+		Mode:       client.CREATION,
+		Input:      input,
+		Output:     tmp + "/crumbls.dat",
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		Batch:      true,
+	}
+	result, err := creator.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crumbls := strings.Split(result, "\n")
+	assert.Equal(t, len(crumbls), len(sources))
+	assert.Equal(t, crumbls[1], "")
+
+	// Partial uncrumbling by the trustee
+	trustee := client.CrumblWorker{
+		Mode:         client.EXTRACTION,
+		Input:        tmp + "/crumbls.dat",
+		SignerKeys:   "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+		Batch:        true,
+	}
+	result, err = trustee.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partialUncrumbs := strings.Split(result, "\n")
+	assert.Equal(t, len(partialUncrumbs), len(sources))
+
+	// Full uncrumbling by the owner
+	var lines []string
+	for i, crumbled := range crumbls {
+		lines = append(lines, strings.TrimSpace(crumbled+" "+partialUncrumbs[i]))
+	}
+	lines[0] = "not-a-crumbl." + lines[0] // Shouldn't prevent the other lines to be processed
+	err = os.WriteFile(tmp+"/uncrumbs.dat", []byte(strings.Join(lines, "\n")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := client.CrumblWorker{
+		Mode:        client.EXTRACTION,
+		Input:       tmp + "/uncrumbs.dat",
+		OwnerKeys:   "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret: dir + "crypto/ecies/keys/owner1.sk",
+		Batch:       true,
+	}
+	result, err = owner.Process(true)
+	assert.Error(t, err, "1 of 2 line(s) failed")
+	assert.DeepEqual(t, strings.Split(result, "\n"), []string{"", "", sources[2]})
+
+	// The output file is appended to as in single mode
+	_, err = creator.Process(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(tmp + "/crumbls.dat")
+	written := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	assert.Equal(t, len(written), 2*len(sources))
+	assert.Equal(t, written[0], crumbls[0])
+}
+
+// TestWorkerRevoke ...
+func TestWorkerRevoke(t *testing.T) {
+	sources := []string{"cdever@edgewhere.fr", "contact@edgewhere.fr"}
+	tmp := t.TempDir()
+	input := tmp + "/sources.txt"
+	err := os.WriteFile(input, []byte(strings.Join(sources, "\n")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creator := client.CrumblWorker{
+		Mode:       client.CREATION,
+		Input:      input,
+		Output:     tmp + "/crumbls.dat",
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub,ecies:" + dir + "crypto/ecies/keys/signer.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		Format:     client.BINARY_FORMAT,
+		Batch:      true,
+	}
+	if _, err = creator.Process(false); err != nil {
+		t.Fatal(err)
+	}
+
+	revoker := client.CrumblWorker{
+		Mode:      client.REVOCATION,
+		Input:     tmp + "/crumbls.dat",
+		Output:    tmp + "/revoked.dat",
+		OwnerKeys: "ecies:" + dir + "crypto/ecies/keys/signer.pub",
+		Batch:     true,
+	}
+	result, err := revoker.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := strings.Split(result, "\n")
+	assert.Equal(t, len(revoked), len(sources))
+	for _, crumbled := range revoked {
+		description, err := core.Inspect(crumbled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(description.Slices[0].Crumbs), 1)
+	}
+
+	// Revoking the last owner would lose the source
+	revoker.Input = tmp + "/revoked.dat"
+	revoker.Output = tmp + "/none.dat"
+	revoker.OwnerKeys = "ecies:" + dir + "crypto/ecies/keys/owner1.pub"
+	_, err = revoker.Process(true)
+	assert.Error(t, err, "2 of 2 line(s) failed")
+}
+
+// TestWorkerBinary ...
+func TestWorkerBinary(t *testing.T) {
+	sources := []string{"cdever@edgewhere.fr", "contact@edgewhere.fr"}
+	tmp := t.TempDir()
+	input := tmp + "/sources.txt"
+	err := os.WriteFile(input, []byte(strings.Join(sources, "\n")+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Creation
+	creator := client.CrumblWorker{
+		Mode:          client.CREATION,
+		Input:         input,
+		Output:        tmp + "/crumbls.bin",
+		OwnerKeys:     "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys:    "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		EmitterKeys:   "ecies:" + dir + "crypto/ecies/keys/emitter.pub",
+		EmitterSecret: dir + "crypto/ecies/keys/emitter.sk",
+		Batch:         true,
+		Format:        client.BINARY_FORMAT,
+	}
+	result, err := creator.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crumbls, err := core.ParseBinaries([]byte(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(crumbls), len(sources))
+
+	// Inspection
+	inspector := client.CrumblWorker{
+		Mode:        client.INSPECTION,
+		Input:       tmp + "/crumbls.bin",
+		Batch:       true,
+		Format:      client.JSON_FORMAT,
+		EmitterKeys: "ecies:" + dir + "crypto/ecies/keys/emitter.pub",
+	}
+	result, err = inspector.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(strings.Split(result, "\n")), len(sources))
+	assert.Assert(t, strings.Contains(result, `"emitter":"`))
+	inspector.EmitterKeys = "ecies:" + dir + "crypto/ecies/keys/signer.pub"
+	inspector.Output = tmp + "/descriptions.json"
+	_, err = inspector.Process(false)
+	assert.Error(t, err, "2 of 2 line(s) failed")
+
+	// Partial uncrumbling by the trustee
+	trustee := client.CrumblWorker{
+		Mode:         client.EXTRACTION,
+		Input:        tmp + "/crumbls.bin",
+		SignerKeys:   "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+	}
+	result, err = trustee.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, strings.HasPrefix(result, crumbls[0].Hashered[:32]))
+
+	inspector.Format = client.BINARY_FORMAT
+	_, err = inspector.Process(false)
+	assert.Error(t, err, "invalid format: binary")
+}
+
+// TestWorkerJSON ...
+func TestWorkerJSON(t *testing.T) {
+	source := "cdever@edgewhere.fr"
+	tmp := t.TempDir()
+
+	// Creation
+	creator := client.CrumblWorker{
+		Mode:       client.CREATION,
+		Output:     tmp + "/crumbl.json",
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		Data:       []string{source},
+		Format:     client.JSON_FORMAT,
+	}
+	crumbled, err := creator.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed core.Crumbled
+	err = json.Unmarshal([]byte(crumbled), &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, parsed.Crumbs[1].Fingerprint, "8586d76f")
+
+	// Partial uncrumbling by the trustee
+	trustee := client.CrumblWorker{
+		Mode:         client.EXTRACTION,
+		Input:        tmp + "/crumbl.json",
+		SignerKeys:   "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+		Format:       client.JSON_FORMAT,
+	}
+	partialUncrumbs, err := trustee.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, strings.HasPrefix(partialUncrumbs, `{"verificationHash":"`))
+
+	// Full uncrumbling by the owner
+	owner := client.CrumblWorker{
+		Mode:        client.EXTRACTION,
+		OwnerKeys:   "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret: dir + "crypto/ecies/keys/owner1.sk",
+		Data:        []string{crumbled, partialUncrumbs},
+		Format:      client.JSON_FORMAT,
+	}
+	result, err := owner.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, result, source)
+}
+
+// TestWorkerStream ...
+func TestWorkerStream(t *testing.T) {
+	sources := []string{"cdever@edgewhere.fr", "", "contact@edgewhere.fr"}
+
+	// Creation
+	var crumbls bytes.Buffer
+	creator := client.CrumblWorker{
+		Mode:       client.CREATION,
+		Input:      client.STDIO,
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		Batch:      true,
+		Stdin:      strings.NewReader(strings.Join(sources, "\n") + "\n"),
+		Stdout:     &crumbls,
+	}
+	result, err := creator.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, crumbls.String(), result+"\n")
+	lines := strings.Split(result, "\n")
+	assert.Equal(t, len(lines), len(sources))
+	assert.Equal(t, lines[1], "")
+
+	// Partial uncrumbling by the trustee
+	var partialUncrumbs bytes.Buffer
+	trustee := client.CrumblWorker{
+		Mode:         client.EXTRACTION,
+		Input:        client.STDIO,
+		SignerKeys:   "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+		Batch:        true,
+		Stdin:        &crumbls,
+		Stdout:       &partialUncrumbs,
+	}
+	_, err = trustee.Process(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbs := strings.Split(strings.TrimSuffix(partialUncrumbs.String(), "\n"), "\n")
+	assert.Equal(t, len(uncrumbs), len(sources))
+
+	// Full uncrumbling by the owner of a single crumbl
+	owner := client.CrumblWorker{
+		Mode:        client.EXTRACTION,
+		Input:       client.STDIO,
+		OwnerKeys:   "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret: dir + "crypto/ecies/keys/owner1.sk",
+		Stdin:       strings.NewReader(lines[2] + "\n" + uncrumbs[2] + "\n"),
+		Stdout:      &bytes.Buffer{},
+	}
+	result, err = owner.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, result, sources[2])
+
+	// Inspection, a malformed line only failing its own result
+	var descriptions, diagnostics bytes.Buffer
+	inspector := client.CrumblWorker{
+		Mode:        client.INSPECTION,
+		Input:       client.STDIO,
+		Batch:       true,
+		Format:      client.JSON_FORMAT,
+		Stdin:       strings.NewReader(lines[0] + "\nnot-a-crumbl\n" + lines[2] + "\n"),
+		Stdout:      &descriptions,
+		Diagnostics: &diagnostics,
+	}
+	_, err = inspector.Process(false)
+	assert.Error(t, err, "1 of 3 line(s) failed")
+	described := strings.Split(strings.TrimSuffix(descriptions.String(), "\n"), "\n")
+	assert.Equal(t, len(described), 3)
+	assert.Assert(t, described[0] != "" && described[1] == "" && described[2] != "")
+	assert.Assert(t, strings.Contains(diagnostics.String(), "ERROR - line 2: "))
+}
+
+// TestWorkerMailbox ...
+func TestWorkerMailbox(t *testing.T) {
+	source := "cdever@edgewhere.fr"
+	tmp := t.TempDir()
+	creator := client.CrumblWorker{
+		Mode:       client.CREATION,
+		Output:     tmp + "/crumbl.dat",
+		OwnerKeys:  "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		SignerKeys: "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		Data:       []string{source},
+	}
+	_, err := creator.Process(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The owner posts its request but no trustee answered yet
+	owner := client.CrumblWorker{
+		Mode:        client.EXTRACTION,
+		Input:       tmp + "/crumbl.dat",
+		OwnerKeys:   "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret: dir + "crypto/ecies/keys/owner1.sk",
+		SignerKeys:  "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		Mailbox:     tmp + "/mailbox",
+		Stdout:      &bytes.Buffer{},
+	}
+	result, err := owner.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, result != source)
+
+	// The trustee answers it
+	var answer bytes.Buffer
+	trustee := client.CrumblWorker{
+		Mode:         client.EXTRACTION,
+		SignerKeys:   "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+		Mailbox:      tmp + "/mailbox",
+		Diagnostics:  &answer,
+	}
+	_, err = trustee.Process(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, answer.String(), "SUCCESS - 1 request(s) answered in "+tmp+"/mailbox\n")
+
+	// The owner finalizes
+	result, err = owner.Process(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, result, source)
+}
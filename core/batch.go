@@ -0,0 +1,277 @@
+package core
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cyrildever/crumbl-exe/models/signer"
+	"github.com/cyrildever/crumbl-exe/obfuscator"
+)
+
+//--- TYPES
+
+// BatchCrumbler crumbles many sources concurrently using the same stakeholders.
+type BatchCrumbler struct {
+	HashEngine     string
+	Owners         []signer.Signer
+	Trustees       []signer.Signer
+	Redundancy     int            // Optional: the number of trustees signing each slice (see encrypter.Dispatcher)
+	Threshold      int            // Optional: the number of trustees needed to recover the data, their slice being shared among them
+	Version        string         // Optional: the version of the crumbl format to use, defaults to VERSION
+	Emitter        signer.Signer  // Optional: as of version 7, the emitter signing every crumbl
+	ObfuscationKey obfuscator.Key // Optional: as of version 8, the key obfuscating every source, defaults to the default key
+	Workers        int            // Defaults to the number of CPUs when not strictly positive
+
+	stats atomic.Pointer[batchStats] // The statistics of the latest batch started
+}
+
+// BatchUncrumbler uncrumbles many crumbls concurrently.
+type BatchUncrumbler struct {
+	Workers int // Defaults to the number of CPUs when not strictly positive
+
+	stats atomic.Pointer[batchStats] // The statistics of the latest batch started
+}
+
+// BatchRekeyer rekeys many crumbls concurrently for the same new stakeholders.
+type BatchRekeyer struct {
+	Target  Crumbl // The new stakeholders and options (see Rekey)
+	Workers int    // Defaults to the number of CPUs when not strictly positive
+
+	stats atomic.Pointer[batchStats] // The statistics of the latest batch started
+}
+
+// BatchResult holds the outcome of the item at Index in a batch, ie. either the crumbled (or uncrumbled) result or an error.
+type BatchResult struct {
+	Index  int
+	Result string
+	Err    error
+}
+
+// BatchStats gives the throughput of a batch.
+type BatchStats struct {
+	Processed int
+	Failed    int
+	Elapsed   time.Duration
+}
+
+type batchStats struct {
+	processed atomic.Int64
+	failed    atomic.Int64
+	start     atomic.Int64
+	end       atomic.Int64
+}
+
+type job func() (string, error)
+
+//--- METHODS
+
+// Process crumbles all the passed sources and returns the results in the same order
+func (b *BatchCrumbler) Process(sources []string) []BatchResult {
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, source := range sources {
+			in <- source
+		}
+	}()
+	return collect(b.Stream(in), len(sources))
+}
+
+// Stream crumbles the sources as they arrive on the passed channel and sends the results in the same order.
+// The returned channel is closed once the input channel is closed and all results are sent.
+func (b *BatchCrumbler) Stream(sources <-chan string) <-chan BatchResult {
+	jobs := make(chan job)
+	go func() {
+		defer close(jobs)
+		for source := range sources {
+			crumbl := Crumbl{
+				Source:         source,
+				HashEngine:     b.HashEngine,
+				Owners:         b.Owners,
+				Trustees:       b.Trustees,
+				Redundancy:     b.Redundancy,
+				Threshold:      b.Threshold,
+				Version:        b.Version,
+				Emitter:        b.Emitter,
+				ObfuscationKey: b.ObfuscationKey,
+			}
+			jobs <- crumbl.doCrumbl
+		}
+	}()
+	return run(jobs, b.Workers, &b.stats)
+}
+
+// Stats returns the statistics of the current or latest batch, ie. the last one started when several streams run concurrently
+func (b *BatchCrumbler) Stats() BatchStats {
+	return snapshot(b.stats.Load())
+}
+
+// Process uncrumbles all the passed items and returns the results in the same order
+func (b *BatchUncrumbler) Process(uncrumbls []Uncrumbl) []BatchResult {
+	in := make(chan Uncrumbl)
+	go func() {
+		defer close(in)
+		for _, uncrumbl := range uncrumbls {
+			in <- uncrumbl
+		}
+	}()
+	return collect(b.Stream(in), len(uncrumbls))
+}
+
+// Stream uncrumbles the items as they arrive on the passed channel and sends the results in the same order.
+// The returned channel is closed once the input channel is closed and all results are sent.
+func (b *BatchUncrumbler) Stream(uncrumbls <-chan Uncrumbl) <-chan BatchResult {
+	jobs := make(chan job)
+	go func() {
+		defer close(jobs)
+		for uncrumbl := range uncrumbls {
+			u := uncrumbl
+			jobs <- func() (string, error) {
+				uncrumbled, err := u.Process()
+				return string(uncrumbled), err
+			}
+		}
+	}()
+	return run(jobs, b.Workers, &b.stats)
+}
+
+// Stats returns the statistics of the current or latest batch, ie. the last one started when several streams run concurrently
+func (b *BatchUncrumbler) Stats() BatchStats {
+	return snapshot(b.stats.Load())
+}
+
+// Process rekeys all the passed crumbls and returns the new crumbls in the same order
+func (b *BatchRekeyer) Process(uncrumbls []Uncrumbl) []BatchResult {
+	in := make(chan Uncrumbl)
+	go func() {
+		defer close(in)
+		for _, uncrumbl := range uncrumbls {
+			in <- uncrumbl
+		}
+	}()
+	return collect(b.Stream(in), len(uncrumbls))
+}
+
+// Stream rekeys the crumbls as they arrive on the passed channel and sends the new crumbls in the same order.
+// The returned channel is closed once the input channel is closed and all results are sent.
+func (b *BatchRekeyer) Stream(uncrumbls <-chan Uncrumbl) <-chan BatchResult {
+	jobs := make(chan job)
+	go func() {
+		defer close(jobs)
+		for uncrumbl := range uncrumbls {
+			rekey := Rekey{
+				Uncrumbl: uncrumbl,
+				Target:   b.Target,
+			}
+			jobs <- rekey.doRekey
+		}
+	}()
+	return run(jobs, b.Workers, &b.stats)
+}
+
+// Stats returns the statistics of the current or latest batch, ie. the last one started when several streams run concurrently
+func (b *BatchRekeyer) Stats() BatchStats {
+	return snapshot(b.stats.Load())
+}
+
+// Throughput returns the number of items processed per second
+func (s BatchStats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Processed) / s.Elapsed.Seconds()
+}
+
+//--- FUNCTIONS
+
+// snapshot returns the passed statistics as of now, if any
+func snapshot(s *batchStats) BatchStats {
+	if s == nil {
+		return BatchStats{}
+	}
+	start := s.start.Load()
+	end := s.end.Load()
+	if end == 0 {
+		end = time.Now().UnixNano()
+	}
+	return BatchStats{
+		Processed: int(s.processed.Load()),
+		Failed:    int(s.failed.Load()),
+		Elapsed:   time.Duration(end - start),
+	}
+}
+
+// run fans the jobs out to the passed number of workers, and fans their results back in the order of the jobs.
+// Each call counts its own statistics, which replace the passed latest ones.
+func run(jobs <-chan job, workers int, latest *atomic.Pointer[batchStats]) <-chan BatchResult {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	stats := &batchStats{}
+	stats.start.Store(time.Now().UnixNano())
+	latest.Store(stats)
+
+	type task struct {
+		index  int
+		do     job
+		result chan BatchResult
+	}
+	tasks := make(chan task)
+	ordered := make(chan chan BatchResult, workers)
+	go func() {
+		defer close(tasks)
+		defer close(ordered)
+		index := 0
+		for j := range jobs {
+			t := task{
+				index:  index,
+				do:     j,
+				result: make(chan BatchResult, 1),
+			}
+			ordered <- t.result
+			tasks <- t
+			index++
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				res, err := t.do()
+				if err != nil {
+					stats.failed.Add(1)
+				}
+				stats.processed.Add(1)
+				t.result <- BatchResult{
+					Index:  t.index,
+					Result: res,
+					Err:    err,
+				}
+			}
+		}()
+	}
+
+	out := make(chan BatchResult)
+	go func() {
+		defer close(out)
+		for result := range ordered {
+			out <- <-result
+		}
+		wg.Wait()
+		stats.end.Store(time.Now().UnixNano())
+	}()
+	return out
+}
+
+func collect(results <-chan BatchResult, size int) []BatchResult {
+	collected := make([]BatchResult, 0, size)
+	for result := range results {
+		collected = append(collected, result)
+	}
+	return collected
+}
@@ -0,0 +1,82 @@
+package core_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+	"github.com/cyrildever/crumbl-exe/utils"
+
+	"gotest.tools/assert"
+)
+
+// TestBatchCrumbler ...
+func TestBatchCrumbler(t *testing.T) {
+	var sources []string
+	for i := 0; i < 50; i++ {
+		sources = append(sources, fmt.Sprintf("user%d@edgewhere.fr", i))
+	}
+	trustee := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           trustee1_pubkey,
+		PrivateKey:          trustee1_privkey,
+	}
+	crumbler := core.BatchCrumbler{
+		HashEngine: crypto.DEFAULT_HASH_ENGINE,
+		Owners: []signer.Signer{{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           owner1_pubkey,
+		}},
+		Trustees: []signer.Signer{trustee},
+		Workers:  4,
+	}
+	results := crumbler.Process(append(sources, ""))
+	assert.Equal(t, len(results), len(sources)+1)
+	for i, source := range sources {
+		assert.Equal(t, results[i].Index, i)
+		assert.NilError(t, results[i].Err)
+		hash, _ := crypto.Hash([]byte(source), crypto.DEFAULT_HASH_ENGINE)
+		assert.Assert(t, strings.HasPrefix(results[i].Result, utils.ToHex(hash)[:32]))
+	}
+	assert.Assert(t, results[len(sources)].Err != nil) // Empty source
+	stats := crumbler.Stats()
+	assert.Equal(t, stats.Processed, len(sources)+1)
+	assert.Equal(t, stats.Failed, 1)
+	assert.Assert(t, stats.Throughput() > 0)
+
+	// Partial uncrumbling of the same batch
+	var uncrumbls []core.Uncrumbl
+	for _, res := range results[:len(sources)] {
+		uncrumbls = append(uncrumbls, core.Uncrumbl{
+			Crumbled: res.Result,
+			Signer:   trustee,
+		})
+	}
+	uncrumbler := core.BatchUncrumbler{}
+	uncrumbled := uncrumbler.Process(uncrumbls)
+	for i, source := range sources {
+		assert.NilError(t, uncrumbled[i].Err)
+		hash, _ := crypto.Hash([]byte(source), crypto.DEFAULT_HASH_ENGINE)
+		assert.Assert(t, strings.HasPrefix(uncrumbled[i].Result, utils.ToHex(hash)))
+	}
+	assert.Equal(t, uncrumbler.Stats().Processed, len(sources))
+
+	// Concurrent batches keep their own statistics
+	var wg sync.WaitGroup
+	for _, size := range []int{1, 2} {
+		wg.Add(1)
+		go func(size int) {
+			defer wg.Done()
+			processed := crumbler.Process(sources[:size])
+			assert.Equal(t, len(processed), size)
+		}(size)
+	}
+	wg.Wait()
+	stats = crumbler.Stats()
+	assert.Assert(t, stats.Processed == 1 || stats.Processed == 2)
+	assert.Equal(t, stats.Failed, 0)
+}
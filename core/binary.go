@@ -0,0 +1,257 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/encrypter"
+	models "github.com/cyrildever/crumbl-exe/models/core"
+	"github.com/cyrildever/crumbl-exe/utils"
+)
+
+// The binary representation of a crumbl holds the same information as its text form without the hexadecimal and base64 encodings.
+// It's made of the following parts, each number being an unsigned varint and each string or byte array being prefixed by its length:
+// - the BINARY_MAGIC bytes;
+// - the version of the crumbl format;
+// - the hash engine, empty if the default one;
+// - the threshold, 0 if none;
+// - the hashered source;
+// - the number of crumbs, then for each crumb: its index, the fingerprint of the recipient's public key if the version holds it,
+// and the encrypted data;
+// - as of version 8, the ID of the obfuscation key, empty if the default one;
+// - as of version 7, the fingerprint of the emitter's public key and its signature, both empty if unsigned.
+// Binary crumbls are self-delimited and may therefore be concatenated, eg. in a file (see ParseBinaries).
+
+const (
+	// BINARY_MAGIC starts any binary crumbl
+	BINARY_MAGIC = "CRBL"
+)
+
+//--- METHODS
+
+// MarshalBinary returns the binary representation of the crumbl.
+// It fails if the crumbl couldn't be restored to the exact same text form from it.
+func (c Crumbled) MarshalBinary() (data []byte, err error) {
+	f, err := featuresOf(c.Version)
+	if err != nil {
+		return
+	}
+	hashEngine := c.HashEngine
+	if hashEngine == crypto.DEFAULT_HASH_ENGINE {
+		hashEngine = ""
+	}
+	hashered, err := fromHex(c.Hashered, "hashered")
+	if err != nil {
+		return
+	}
+	data = []byte(BINARY_MAGIC)
+	data = appendString(data, c.Version)
+	data = appendString(data, hashEngine)
+	data = binary.AppendUvarint(data, uint64(c.Threshold))
+	data = appendBytes(data, hashered)
+	data = binary.AppendUvarint(data, uint64(len(c.Crumbs)))
+	for _, crumb := range c.Crumbs {
+		data = binary.AppendUvarint(data, uint64(crumb.Index))
+		if f.layout.Fingerprint {
+			fingerprint, e := fromHex(crumb.Fingerprint, "fingerprint")
+			if e != nil {
+				return nil, e
+			}
+			if len(fingerprint) != crypto.FINGERPRINT_LENGTH/2 {
+				return nil, fmt.Errorf("%w: invalid fingerprint %s", ErrInvalidBinary, crumb.Fingerprint)
+			}
+			data = append(data, fingerprint...)
+		}
+		encrypted := crumb.Encrypted.Bytes()
+		if models.ToBase64(encrypted) != crumb.Encrypted || crumb.Length != len(crumb.Encrypted) {
+			return nil, fmt.Errorf("%w: invalid encrypted crumb at index %d", ErrInvalidBinary, crumb.Index)
+		}
+		data = appendBytes(data, encrypted)
+	}
+	if f.obfuscationKey {
+		keyID, e := fromHex(c.ObfuscationKey, "obfuscation key")
+		if e != nil {
+			return nil, e
+		}
+		data = appendBytes(data, keyID)
+	}
+	if f.signature {
+		emitter, e := fromHex(c.Emitter, "emitter")
+		if e != nil {
+			return nil, e
+		}
+		signature, e := base64.StdEncoding.DecodeString(c.Signature)
+		if e != nil || base64.StdEncoding.EncodeToString(signature) != c.Signature {
+			return nil, fmt.Errorf("%w: invalid signature", ErrInvalidBinary)
+		}
+		data = appendBytes(data, emitter)
+		data = appendBytes(data, signature)
+	}
+	return
+}
+
+// UnmarshalBinary fills the crumbl from the passed binary representation
+func (c *Crumbled) UnmarshalBinary(data []byte) error {
+	parsed, err := ParseBinary(data)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+//--- FUNCTIONS
+
+// IsBinary returns `true` if the passed data starts like a binary crumbl
+func IsBinary(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(BINARY_MAGIC))
+}
+
+// ParseBinary returns the parsed representation of the passed binary crumbl, or an error if it's malformed or its version is not supported
+func ParseBinary(data []byte) (c Crumbled, err error) {
+	c, n, err := decodeBinary(data)
+	if err != nil {
+		return
+	}
+	if n != len(data) {
+		err = fmt.Errorf("%w: trailing bytes at offset %d", ErrInvalidBinary, n)
+	}
+	return
+}
+
+// ParseBinaries returns the parsed representations of the passed concatenated binary crumbls
+func ParseBinaries(data []byte) (crumbls []Crumbled, err error) {
+	for len(data) > 0 {
+		c, n, e := decodeBinary(data)
+		if e != nil {
+			return nil, e
+		}
+		crumbls = append(crumbls, c)
+		data = data[n:]
+	}
+	return
+}
+
+// decodeBinary parses the binary crumbl at the start of the passed data and returns the number of bytes it takes
+func decodeBinary(data []byte) (c Crumbled, n int, err error) {
+	if !IsBinary(data) {
+		err = fmt.Errorf("%w: missing magic bytes", ErrInvalidBinary)
+		return
+	}
+	r := binaryReader{data: data, offset: len(BINARY_MAGIC)}
+	version := string(r.bytes())
+	if r.err != nil {
+		err = r.err
+		return
+	}
+	f, err := featuresOf(version)
+	if err != nil {
+		return
+	}
+	hashEngine := string(r.bytes())
+	threshold := r.uvarint()
+	hashered := r.bytes()
+	numberOfCrumbs := r.uvarint()
+	var crumbs encrypter.Crumbs
+	for i := 0; i < numberOfCrumbs && r.err == nil; i++ {
+		crumb := encrypter.Crumb{Index: r.uvarint()}
+		if f.layout.Fingerprint {
+			crumb.Fingerprint = utils.ToHex(r.next(crypto.FINGERPRINT_LENGTH / 2))
+		}
+		crumb.Encrypted = models.ToBase64(r.bytes())
+		crumb.Length = len(crumb.Encrypted)
+		crumbs = append(crumbs, crumb)
+	}
+	var obfuscationKey, emitter, signature string
+	if f.obfuscationKey {
+		obfuscationKey = utils.ToHex(r.bytes())
+	}
+	if f.signature {
+		emitter = utils.ToHex(r.bytes())
+		if sig := r.bytes(); len(sig) > 0 {
+			signature = base64.StdEncoding.EncodeToString(sig)
+		}
+	}
+	if r.err != nil {
+		err = r.err
+		return
+	}
+
+	// Check the decoded crumbl as if it were parsed from its text form
+	c, err = Parse(Crumbled{
+		Version:        version,
+		Hashered:       utils.ToHex(hashered),
+		Crumbs:         crumbs,
+		Threshold:      threshold,
+		HashEngine:     hashEngine,
+		ObfuscationKey: obfuscationKey,
+		Emitter:        emitter,
+		Signature:      signature,
+	}.String())
+	n = r.offset
+	return
+}
+
+func appendBytes(data []byte, b []byte) []byte {
+	data = binary.AppendUvarint(data, uint64(len(b)))
+	return append(data, b...)
+}
+
+func appendString(data []byte, str string) []byte {
+	return appendBytes(data, []byte(str))
+}
+
+// fromHex decodes the passed hexadecimal string, making sure it would be encoded back to the same string
+func fromHex(str string, name string) ([]byte, error) {
+	decoded, err := utils.FromHex(str)
+	if err != nil || utils.ToHex(decoded) != str {
+		return nil, fmt.Errorf("%w: invalid %s %s", ErrInvalidBinary, name, str)
+	}
+	return decoded, nil
+}
+
+// binaryReader reads the parts of a binary crumbl, keeping the first error met
+type binaryReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func (r *binaryReader) uvarint() int {
+	if r.err != nil {
+		return 0
+	}
+	value, n := binary.Uvarint(r.data[r.offset:])
+	if n <= 0 || value > uint64(len(r.data)) { // No number in a binary crumbl may exceed its size
+		r.err = fmt.Errorf("%w: invalid number at offset %d", ErrInvalidBinary, r.offset)
+		return 0
+	}
+	r.offset += n
+	return int(value)
+}
+
+func (r *binaryReader) next(length int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.offset+length > len(r.data) {
+		r.err = fmt.Errorf("%w: unexpected end of data at offset %d", ErrInvalidBinary, r.offset)
+		return nil
+	}
+	b := r.data[r.offset : r.offset+length]
+	r.offset += length
+	return b
+}
+
+func (r *binaryReader) bytes() []byte {
+	return r.next(r.uvarint())
+}
+
+//--- ERRORS
+
+// ErrInvalidBinary is returned when a binary crumbl is malformed
+var ErrInvalidBinary = errors.New("invalid binary crumbl")
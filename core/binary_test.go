@@ -0,0 +1,118 @@
+package core_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+	"github.com/cyrildever/crumbl-exe/obfuscator"
+
+	"gotest.tools/assert"
+)
+
+// TestMarshalBinary ...
+func TestMarshalBinary(t *testing.T) {
+	var crumbls []string
+	crumbls = append(crumbls, "580fb8a91f05833200dea7d33536aaec9d7ceb256a9858ee68e330e126ba409d0004624532000cRWRnZXdoZXJl018586d76f000cRWRnZXdoZXJl.4.t:1")
+	for _, engine := range []string{crypto.DEFAULT_HASH_ENGINE, crypto.SHA512_HASH_ENGINE} {
+		c := core.Crumbl{
+			Source:     "cdever@edgewhere.fr",
+			HashEngine: engine,
+			Owners: []signer.Signer{
+				{
+					EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+					PublicKey:           owner1_pubkey,
+				},
+			},
+			Trustees: []signer.Signer{
+				{
+					EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+					PublicKey:           trustee1_pubkey,
+				},
+				{
+					EncryptionAlgorithm: crypto.RSA_ALGORITHM,
+					PublicKey:           trustee2_pubkey,
+				},
+			},
+		}
+		crumbled, err := c.Process()
+		if err != nil {
+			t.Fatal(err)
+		}
+		crumbls = append(crumbls, crumbled)
+	}
+	signed := core.Crumbl{
+		Source: "cdever@edgewhere.fr",
+		Owners: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+				PublicKey:           owner1_pubkey,
+			},
+		},
+		Trustees: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+				PublicKey:           trustee1_pubkey,
+			},
+		},
+		Emitter: signer.Signer{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           emitter_pubkey,
+			PrivateKey:          emitter_privkey,
+		},
+	}
+	crumbled, err := signed.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crumbls = append(crumbls, crumbled)
+	signed.ObfuscationKey, _ = obfuscator.GenerateKey()
+	crumbled, err = signed.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crumbls = append(crumbls, crumbled)
+
+	var concatenated []byte
+	for _, crumbled := range crumbls {
+		parsed, err := core.Parse(crumbled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := parsed.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Assert(t, core.IsBinary(data))
+		assert.Assert(t, len(data) < len(crumbled))
+		var unmarshalled core.Crumbled
+		err = unmarshalled.UnmarshalBinary(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, unmarshalled.String(), crumbled)
+		concatenated = append(concatenated, data...)
+
+		_, err = core.ParseBinary(data[:len(data)-1])
+		assert.Assert(t, errors.Is(err, core.ErrInvalidBinary))
+		_, err = core.ParseBinary(append(data, 0))
+		assert.Error(t, err, fmt.Sprintf("invalid binary crumbl: trailing bytes at offset %d", len(data)))
+	}
+
+	parsed, err := core.ParseBinaries(concatenated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(parsed), len(crumbls))
+	for i, p := range parsed {
+		assert.Equal(t, p.String(), crumbls[i])
+	}
+
+	_, err = core.ParseBinary([]byte(crumbls[0]))
+	assert.Error(t, err, "invalid binary crumbl: missing magic bytes")
+	_, err = core.ParseBinary([]byte("CRBL\x019"))
+	assert.Assert(t, errors.Is(err, core.ErrVersionMismatch))
+}
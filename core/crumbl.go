@@ -1,32 +1,35 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"strings"
 
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/crypto/shamir"
+	"github.com/cyrildever/crumbl-exe/decrypter"
 	"github.com/cyrildever/crumbl-exe/encrypter"
 	"github.com/cyrildever/crumbl-exe/hasher"
+	"github.com/cyrildever/crumbl-exe/models/core"
 	"github.com/cyrildever/crumbl-exe/models/signer"
 	"github.com/cyrildever/crumbl-exe/obfuscator"
 	"github.com/cyrildever/crumbl-exe/padder"
 	"github.com/cyrildever/crumbl-exe/slicer"
-	"github.com/cyrildever/feistel"
-)
-
-const (
-	// VERSION ...
-	VERSION = "1" // TODO Change when necessary (change of hash algorithm, modification of string structure, etc.)
 )
 
 //--- TYPES
 
 // Crumbl ...
 type Crumbl struct {
-	Source     string
-	HashEngine string
-	Owners     []signer.Signer
-	Trustees   []signer.Signer
+	Source         string
+	HashEngine     string // Optional: as of version 5, the hash engine to use for the hashered source, defaults to crypto.DEFAULT_HASH_ENGINE
+	Owners         []signer.Signer
+	Trustees       []signer.Signer
+	Redundancy     int            // Optional: the number of trustees signing each slice (see encrypter.Dispatcher)
+	Threshold      int            // Optional: as of version 4, the number of trustees needed to recover the data, their slice being shared among them
+	Version        string         // Optional: the version of the crumbl format to use, defaults to VERSION
+	Emitter        signer.Signer  // Optional: as of version 7, the emitter signing the crumbl with its private key
+	ObfuscationKey obfuscator.Key // Optional: as of version 8, the key obfuscating the source, defaults to the default key (see obfuscator.DefaultKey)
 }
 
 //--- METHODS
@@ -53,7 +56,6 @@ func (c *Crumbl) ToFile(filename string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	fmt.Fprintf(os.Stdout, "SUCCESS - crumbl successfully saved to %v\n", filename)
 	return crumbled, nil
 }
 
@@ -70,10 +72,31 @@ func (c *Crumbl) ToStdOut() (result string, err error) {
 // doCrumbl build the actual crumbled string which would be composed of:
 // - the hash of the source (in hexadecimal);
 // - the concatenation of the stringified encrypted crumbs;
-// - a dot followed by the version number of the Crumb&trade; engine used.
+// - a dot followed by the version number of the Crumb&trade; engine used;
+// - the optional fields of the version, eg. the signature of the emitter.
 func (c *Crumbl) doCrumbl() (crumbled string, err error) {
+	output, _, err := c.crumble()
+	if err != nil {
+		return
+	}
+	crumbled = output.String()
+	return
+}
+
+// crumble builds the crumbl along with the clear slices it encrypted, as the uncrumbs deciphering its crumbs would return them,
+// ie. the shares of the trustees' slice in threshold mode, eg. to check the crumbl by uncrumbling it in memory
+func (c *Crumbl) crumble() (output Crumbled, slices []decrypter.Uncrumb, err error) {
+	version, f, hashEngine, err := c.check()
+	if err != nil {
+		return
+	}
+
 	// 1-Obfuscate
-	obfuscated, err := obfuscator.NewObfuscator(feistel.NewFPECipher(obfuscator.DEFAULT_HASH_ENGINE, obfuscator.DEFAULT_KEY_STRING, obfuscator.DEFAULT_ROUNDS)).Apply(c.Source)
+	o, err := obfuscator.NewObfuscatorWith(c.ObfuscationKey)
+	if err != nil {
+		return
+	}
+	obfuscated, err := o.Apply(c.Source)
 	if err != nil {
 		return
 	}
@@ -85,9 +108,9 @@ func (c *Crumbl) doCrumbl() (crumbled string, err error) {
 	}
 
 	// 3-Slice
-	numberOfSlices := 1 + min(len(c.Trustees), slicer.MAX_SLICES) // Owners only sign the first slice
+	numberOfSlices := c.numberOfSlices(f)
 	deltaMax := slicer.GetDeltaMax(len(padded), numberOfSlices)
-	slices, err := slicer.Slicer{
+	sliced, err := slicer.Slicer{
 		NumberOfSlices: numberOfSlices,
 		DeltaMax:       deltaMax,
 	}.Apply(string(padded))
@@ -97,52 +120,209 @@ func (c *Crumbl) doCrumbl() (crumbled string, err error) {
 
 	// 4-Encrypt
 	var crumbs []encrypter.Crumb
+	slices = append(slices, toUncrumb(sliced[0], 0))
 	for _, owner := range c.Owners {
-		crumb, e := encrypter.Encrypt(slices[0], 0, owner)
+		crumb, e := encrypter.Encrypt(sliced[0], 0, owner)
 		if e != nil {
 			err = e
 			return
 		}
 		crumbs = append(crumbs, crumb)
 	}
-	dispatcher := encrypter.Dispatcher{
-		NumberOfSlices: numberOfSlices,
-		Trustees:       c.Trustees,
-	}
-	allocation, err := dispatcher.Allocate()
-	if err != nil {
-		return
-	}
-	for i, trustees := range allocation {
-		for _, trustee := range trustees {
-			crumb, e := encrypter.Encrypt(slices[i], i, trustee)
+	if c.Threshold != 0 {
+		// Each trustee gets its own share of the second slice at the index matching its x coordinate
+		shares, e := shamir.Split([]byte(sliced[1]), len(c.Trustees), c.Threshold)
+		if e != nil {
+			err = e
+			return
+		}
+		for i, trustee := range c.Trustees {
+			slices = append(slices, toUncrumb(slicer.Slice(shares[i]), i+1))
+			crumb, e := encrypter.Encrypt(slicer.Slice(shares[i]), i+1, trustee)
 			if e != nil {
 				err = e
 				return
 			}
 			crumbs = append(crumbs, crumb)
 		}
+	} else {
+		dispatcher := encrypter.Dispatcher{
+			NumberOfSlices: numberOfSlices,
+			Trustees:       c.Trustees,
+			Redundancy:     c.Redundancy,
+		}
+		allocation, e := dispatcher.Allocate()
+		if e != nil {
+			err = e
+			return
+		}
+		for i, trustees := range allocation {
+			slices = append(slices, toUncrumb(sliced[i], i))
+			for _, trustee := range trustees {
+				crumb, e := encrypter.Encrypt(sliced[i], i, trustee)
+				if e != nil {
+					err = e
+					return
+				}
+				crumbs = append(crumbs, crumb)
+			}
+		}
+	}
+
+	for _, crumb := range crumbs {
+		if !f.layout.Fits(crumb.Length) {
+			err = fmt.Errorf("%w: %d characters at index %d in version %s", encrypter.ErrCrumbTooLong, crumb.Length, crumb.Index, version)
+			return
+		}
 	}
 
 	// 5-Hash the source string
-	hashered, err := hasher.Apply(c.Source, crumbs)
+	hashered, err := hasher.ApplyWithEngine(c.Source, crumbs, hashEngine)
 	if err != nil {
 		return
 	}
 
-	// 6- Finalize the output string
-	var stringifiedCrumbs []string
-	for _, c := range crumbs {
-		stringifiedCrumbs = append(stringifiedCrumbs, c.String())
+	// 6- Finalize the output, signing it if need be
+	output = Crumbled{
+		Version:    version,
+		Hashered:   hashered,
+		Crumbs:     crumbs,
+		Threshold:  c.Threshold,
+		HashEngine: hashEngine,
 	}
-	crumbled = hashered + strings.Join(stringifiedCrumbs, "") + "." + VERSION
+	if !c.ObfuscationKey.IsDefault() {
+		output.ObfuscationKey = c.ObfuscationKey.ID()
+	}
+	if len(c.Emitter.PrivateKey) > 0 {
+		if err = output.Sign(c.Emitter); err != nil {
+			return
+		}
+	}
+	return
+}
 
+// Check returns an error if the parameters of the crumbl are invalid or not supported by its version, without needing its source,
+// eg. to reject them before crumbling any source
+func (c *Crumbl) Check() error {
+	_, f, _, err := c.check()
+	if err != nil || c.Threshold != 0 {
+		return err
+	}
+	dispatcher := encrypter.Dispatcher{
+		NumberOfSlices: c.numberOfSlices(f),
+		Trustees:       c.Trustees,
+		Redundancy:     c.Redundancy,
+	}
+	_, err = dispatcher.Allocate()
+	return err
+}
+
+// check returns the version, its features and the hash engine of the crumbl after checking its parameters against them
+func (c *Crumbl) check() (version string, f features, hashEngine string, err error) {
+	version = c.Version
+	if version == "" {
+		version = VERSION
+	}
+	f, err = featuresOf(version)
+	if err != nil {
+		return
+	}
+	if c.Threshold != 0 {
+		if !f.threshold {
+			err = fmt.Errorf("threshold not supported in version %s", version)
+			return
+		}
+		if c.Redundancy != 0 {
+			err = errors.New("redundancy can't be used along with a threshold")
+			return
+		}
+		if c.Threshold < 1 || c.Threshold > len(c.Trustees) {
+			err = fmt.Errorf("%w: %d of %d", shamir.ErrInvalidThreshold, c.Threshold, len(c.Trustees))
+			return
+		}
+	}
+	hashEngine = c.HashEngine
+	if hashEngine == "" {
+		hashEngine = crypto.DEFAULT_HASH_ENGINE
+	}
+	if !crypto.ExistsHashEngine(hashEngine) {
+		err = fmt.Errorf("%w: %s", crypto.ErrUnknownHashEngine, hashEngine)
+		return
+	}
+	if hashEngine != crypto.DEFAULT_HASH_ENGINE && !f.hashEngine {
+		err = fmt.Errorf("hash engine not supported in version %s", version)
+		return
+	}
+	if !c.ObfuscationKey.IsDefault() && !f.obfuscationKey {
+		err = fmt.Errorf("obfuscation key not supported in version %s", version)
+		return
+	}
+	if len(c.Emitter.PrivateKey) > 0 && !f.signature {
+		err = fmt.Errorf("signature not supported in version %s", version)
+		return
+	}
 	return
 }
 
+// numberOfSlices returns the number of slices of the crumbl in a version with the passed features
+func (c *Crumbl) numberOfSlices(f features) int {
+	if c.Threshold != 0 {
+		return 2 // The second slice is shared among trustees
+	}
+	return 1 + min(len(c.Trustees), f.trusteeSlices) // Owners only sign the first slice
+}
+
+// toUncrumb returns the passed clear slice at the passed index as deciphering its crumb would
+func toUncrumb(slice slicer.Slice, index int) decrypter.Uncrumb {
+	return decrypter.Uncrumb{
+		Deciphered: core.ToBase64([]byte(slice)),
+		Index:      index,
+	}
+}
+
 func min(x, y int) int {
 	if x < y {
 		return x
 	}
 	return y
 }
+
+//--- ERRORS
+
+var (
+	// ErrVersionMismatch is returned when the version of a crumbl or a partial uncrumb is not supported
+	ErrVersionMismatch = errors.New("incompatible version")
+
+	// ErrVerificationHashMismatch is returned when the uncrumbled data doesn't match the verification hash of the crumbl
+	ErrVerificationHashMismatch = errors.New("source has not checked verification hash")
+
+	// ErrAlreadyAnOwner is returned when adding an owner whose crumb is already in the crumbl
+	ErrAlreadyAnOwner = errors.New("already an owner of the crumbl")
+
+	// ErrIncompleteUncrumbs is returned when rekeying a crumbl without all the partial uncrumbs needed to recover its source
+	ErrIncompleteUncrumbs = errors.New("missing partial uncrumbs to recover the source")
+
+	// ErrInvalidRekeyedCrumbl is returned when the new crumbl of a rekeyed one doesn't uncrumble back to its source
+	ErrInvalidRekeyedCrumbl = errors.New("invalid rekeyed crumbl")
+
+	// ErrNoOwnerLeft is returned when revoking all the owners of a crumbl, whose source would then be lost
+	ErrNoOwnerLeft = errors.New("no owner left in the crumbl")
+
+	// ErrNotAnOwner is returned when the passed owner has no crumb in the crumbl it should delegate
+	ErrNotAnOwner = errors.New("not an owner of the crumbl")
+
+	// ErrNotATrustee is returned when a trusted signer has no crumb to decipher in the crumbl it should partially uncrumble
+	ErrNotATrustee = errors.New("no crumb for this trustee")
+
+	// ErrSignatureNeeded is returned when modifying a signed crumbl without the emitter's private key to sign it again
+	ErrSignatureNeeded = errors.New("signed crumbl: the emitter's private key is needed to sign it again")
+
+	// ErrUnknownObfuscationKey is returned when the obfuscation key referenced by a crumbl is not among the passed ones
+	ErrUnknownObfuscationKey = errors.New("unknown obfuscation key")
+
+	// ErrUnsignedCrumbl is returned when a crumbl expected to be signed by its emitter is not
+	ErrUnsignedCrumbl = errors.New("unsigned crumbl")
+
+	// ErrUnknownEmitter is returned when a crumbl is signed by none of the authorized emitters
+	ErrUnknownEmitter = errors.New("unknown emitter of crumbl")
+)
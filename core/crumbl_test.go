@@ -1,14 +1,19 @@
 package core_test
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"testing"
 
 	"github.com/cyrildever/crumbl-exe/core"
 	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/crypto/shamir"
+	"github.com/cyrildever/crumbl-exe/encrypter"
 	"github.com/cyrildever/crumbl-exe/models/signer"
 	"github.com/cyrildever/crumbl-exe/utils"
+
+	"gotest.tools/assert"
 )
 
 var (
@@ -20,6 +25,8 @@ var (
 	trustee2_privkey, _ = ioutil.ReadFile("../crypto/rsa/keys/trustee2.sk")
 	trustee3_pubkey, _  = utils.FromHex("04e8d931172dd09cff868ec36235512cfedfef632f81d50d7272490c5cfe8efffe3cfcde7f0eba4759456489d3735bf7510a7c4478e8bd9c37873afd0b798693bd")
 	trustee3_privkey, _ = utils.FromHex("8bf49f4ccb80e659c65a7bf127a292d30584d0b9f9bf1cd84bee425eb2a3ab9e")
+	emitter_pubkey, _   = utils.FromHex("04e36e28204270d5206c84674ed71acb7491f2bcb52e84a649e136219896224c1b5ec4c7501a513f288c6cf6055c458bcf3b0f212601ffdfaf50981e20238dc9f6") // see '../crypto/ecies/keys/emitter.pub'
+	emitter_privkey, _  = utils.FromHex("0357ddca67ce0f87b6119e38756e5704c323c75d05e3bd6e0f24fb8129af24f2")                                                                   // see '../crypto/ecies/keys/emitter.sk'
 )
 
 // TestCrumbl ...
@@ -87,3 +94,29 @@ func TestCrumbl(t *testing.T) {
 	fmt.Println(crumbled)
 	// assert.Assert(t, false)
 }
+
+// TestCrumblCheck ...
+func TestCrumblCheck(t *testing.T) {
+	c := core.Crumbl{
+		Owners: []signer.Signer{{EncryptionAlgorithm: crypto.ECIES_ALGORITHM, PublicKey: owner1_pubkey}},
+		Trustees: []signer.Signer{
+			{EncryptionAlgorithm: crypto.ECIES_ALGORITHM, PublicKey: trustee1_pubkey},
+			{EncryptionAlgorithm: crypto.RSA_ALGORITHM, PublicKey: trustee2_pubkey},
+		},
+	}
+	assert.NilError(t, c.Check())
+
+	invalid := c
+	invalid.HashEngine = "md5"
+	assert.Assert(t, errors.Is(invalid.Check(), crypto.ErrUnknownHashEngine))
+	invalid = c
+	invalid.Threshold = 3
+	assert.Assert(t, errors.Is(invalid.Check(), shamir.ErrInvalidThreshold))
+	invalid = c
+	invalid.Redundancy = 2
+	assert.Assert(t, errors.Is(invalid.Check(), encrypter.ErrInvalidRedundancy))
+	invalid = c
+	invalid.Version = "1"
+	invalid.Threshold = 2
+	assert.Error(t, invalid.Check(), "threshold not supported in version 1")
+}
@@ -0,0 +1,244 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/encrypter"
+	"github.com/cyrildever/crumbl-exe/hasher"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+	"github.com/cyrildever/crumbl-exe/obfuscator"
+	"github.com/cyrildever/crumbl-exe/utils"
+)
+
+const (
+	// THRESHOLD_TAG prefixes the threshold field in the trailer of a crumbl
+	THRESHOLD_TAG = "t"
+
+	// HASH_ENGINE_TAG prefixes the hash engine field in the trailer of a crumbl, only present if not the default one
+	HASH_ENGINE_TAG = "h"
+
+	// OBFUSCATION_KEY_TAG prefixes the ID of the obfuscation key in the trailer of a crumbl, only present if not the default one
+	OBFUSCATION_KEY_TAG = "k"
+
+	// SIGNATURE_TAG prefixes the last field in the trailer of a signed crumbl or partial uncrumbs, its value being made of
+	// the fingerprint of the signer's public key, a colon and the base64-encoded signature of all that precedes the field
+	SIGNATURE_TAG = "s"
+)
+
+//--- TYPES
+
+// Crumbled is the parsed representation of a crumbl, ie. of a string made of:
+// - the hashered source (in hexadecimal);
+// - the concatenation of the stringified encrypted crumbs;
+// - a dot followed by the version number of the crumbl format;
+// - as of version 4, any optional field as a dot followed by its tag, a colon and its value, eg. `.t:2` for the threshold
+// or, as of version 8, `.k:<key ID>` for the obfuscation key;
+// - as of version 7, the optional signature of the emitter as the last field.
+type Crumbled struct {
+	Version        string           `json:"version"`
+	Hashered       string           `json:"hashered"`
+	Crumbs         encrypter.Crumbs `json:"crumbs"`
+	Threshold      int              `json:"threshold,omitempty"`      // The number of trustees needed to recover the data when their slice is shared, 0 otherwise
+	HashEngine     string           `json:"hashEngine,omitempty"`     // The hash engine of the hashered source, crypto.DEFAULT_HASH_ENGINE if empty
+	ObfuscationKey string           `json:"obfuscationKey,omitempty"` // The ID of the obfuscation key of the source, the default key if empty (see obfuscator.Key)
+	Emitter        string           `json:"emitter,omitempty"`        // The fingerprint of the emitter's public key if signed
+	Signature      string           `json:"signature,omitempty"`      // The base64-encoded signature of the emitter (see crypto.Sign)
+}
+
+//--- METHODS
+
+// String returns the crumbled string, the crumbs being stringified according to the version
+func (c Crumbled) String() string {
+	str := c.content()
+	if c.Signature != "" {
+		str += "." + SIGNATURE_TAG + ":" + c.Emitter + ":" + c.Signature
+	}
+	return str
+}
+
+// Sign signs the crumbl with the private key of the passed emitter, as of version 7
+func (c *Crumbled) Sign(emitter signer.Signer) error {
+	if f, err := featuresOf(c.Version); err != nil {
+		return err
+	} else if !f.signature {
+		return fmt.Errorf("signature not supported in version %s", c.Version)
+	}
+	fingerprint, err := crypto.Fingerprint(emitter.PublicKey, emitter.EncryptionAlgorithm)
+	if err != nil {
+		return err
+	}
+	signature, err := crypto.Sign([]byte(c.content()), emitter.PrivateKey, emitter.EncryptionAlgorithm)
+	if err != nil {
+		return err
+	}
+	c.Emitter = fingerprint
+	c.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// Verify checks that the crumbl is signed by any of the passed emitters, returning ErrUnsignedCrumbl if it's not signed at all,
+// ErrUnknownEmitter if signed by someone else, or crypto.ErrInvalidSignature if it was tampered with.
+func (c Crumbled) Verify(emitters []signer.Signer) error {
+	if c.Signature == "" {
+		return ErrUnsignedCrumbl
+	}
+	for _, emitter := range emitters {
+		if fingerprint, e := crypto.Fingerprint(emitter.PublicKey, emitter.EncryptionAlgorithm); e != nil || fingerprint != c.Emitter {
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(c.Signature)
+		if err != nil {
+			return crypto.ErrInvalidSignature
+		}
+		return crypto.Verify([]byte(c.content()), signature, emitter.PublicKey, emitter.EncryptionAlgorithm)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownEmitter, c.Emitter)
+}
+
+// withCrumbs returns the crumbled string with the passed crumbs instead of its own, the hashered prefix being computed again
+// for their owners' crumbs so that the verification hash is left unchanged, and signed again by the passed emitter if it holds a private key
+func (c Crumbled) withCrumbs(crumbs encrypter.Crumbs, emitter signer.Signer) (string, error) {
+	verificationHash, err := hasher.Unapply(c.Hashered, c.Crumbs)
+	if err != nil {
+		return "", err
+	}
+	hashered, err := hasher.ApplyToHash(verificationHash, crumbs)
+	if err != nil {
+		return "", err
+	}
+	c.Hashered = hashered
+	c.Crumbs = crumbs
+	c.Emitter, c.Signature = "", ""
+	if len(emitter.PrivateKey) > 0 {
+		if err = c.Sign(emitter); err != nil {
+			return "", err
+		}
+	}
+	return c.String(), nil
+}
+
+// content returns the crumbled string without its signature, ie. the signed part of it
+func (c Crumbled) content() string {
+	f, _ := featuresOf(c.Version)
+	var stringifiedCrumbs []string
+	for _, crumb := range c.Crumbs {
+		stringifiedCrumbs = append(stringifiedCrumbs, crumb.Stringify(f.layout))
+	}
+	trailer := "." + c.Version
+	if c.Threshold > 0 {
+		trailer += "." + THRESHOLD_TAG + ":" + strconv.Itoa(c.Threshold)
+	}
+	if c.HashEngine != "" && c.HashEngine != crypto.DEFAULT_HASH_ENGINE {
+		trailer += "." + HASH_ENGINE_TAG + ":" + c.HashEngine
+	}
+	if c.ObfuscationKey != "" {
+		trailer += "." + OBFUSCATION_KEY_TAG + ":" + c.ObfuscationKey
+	}
+	return c.Hashered + strings.Join(stringifiedCrumbs, "") + trailer
+}
+
+// UnmarshalJSON fills the crumbl from its JSON representation, checking it as if it were parsed from its text form
+func (c *Crumbled) UnmarshalJSON(data []byte) error {
+	type plain Crumbled // Prevents recursion
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	parsed, err := Parse(Crumbled(p).String())
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+//--- FUNCTIONS
+
+// Parse returns the parsed representation of the passed crumbled string, or an error if it's malformed or its version is not supported
+func Parse(crumbled string) (c Crumbled, err error) {
+	parts := strings.SplitN(crumbled, ".", 2)
+	if len(parts) != 2 {
+		err = errors.New("invalid crumbled string: missing version")
+		return
+	}
+	fields := strings.Split(parts[1], ".")
+	f, err := featuresOf(fields[0])
+	if err != nil {
+		return
+	}
+	for i, field := range fields[1:] {
+		tagged := strings.SplitN(field, ":", 2)
+		if len(tagged) != 2 {
+			err = fmt.Errorf("invalid crumbled string: malformed field %s", field)
+			return
+		}
+		switch {
+		case tagged[0] == SIGNATURE_TAG && f.signature && i == len(fields)-2:
+			signed := strings.SplitN(tagged[1], ":", 2)
+			if len(signed) != 2 || len(signed[0]) != crypto.FINGERPRINT_LENGTH || signed[1] == "" {
+				err = fmt.Errorf("invalid crumbled string: malformed signature %s", tagged[1])
+				return
+			}
+			c.Emitter = signed[0]
+			c.Signature = signed[1]
+		case tagged[0] == THRESHOLD_TAG && f.threshold && c.Threshold == 0:
+			threshold, e := strconv.Atoi(tagged[1])
+			if e != nil || threshold < 1 {
+				err = fmt.Errorf("invalid crumbled string: invalid threshold %s", tagged[1])
+				return
+			}
+			c.Threshold = threshold
+		case tagged[0] == HASH_ENGINE_TAG && f.hashEngine && c.HashEngine == "":
+			if !crypto.ExistsHashEngine(tagged[1]) {
+				err = fmt.Errorf("%w: %s", crypto.ErrUnknownHashEngine, tagged[1])
+				return
+			}
+			c.HashEngine = tagged[1]
+		case tagged[0] == OBFUSCATION_KEY_TAG && f.obfuscationKey && c.ObfuscationKey == "":
+			if decoded, e := utils.FromHex(tagged[1]); e != nil || len(tagged[1]) != obfuscator.KEY_ID_LENGTH || utils.ToHex(decoded) != tagged[1] {
+				err = fmt.Errorf("invalid crumbled string: invalid obfuscation key %s", tagged[1])
+				return
+			}
+			c.ObfuscationKey = tagged[1]
+		default:
+			err = fmt.Errorf("invalid crumbled string: unexpected field %s in version %s", field, fields[0])
+			return
+		}
+	}
+	if c.HashEngine == "" {
+		c.HashEngine = crypto.DEFAULT_HASH_ENGINE
+	}
+	hashLength, err := crypto.HashLength(c.HashEngine)
+	if err != nil {
+		return
+	}
+	if len(parts[0]) < hashLength {
+		err = errors.New("invalid crumbled string: too short")
+		return
+	}
+	crumbs, err := parse(parts[0][hashLength:], hashLength, f.layout)
+	if err != nil {
+		return
+	}
+	c.Version = fields[0]
+	c.Hashered = parts[0][:hashLength]
+	c.Crumbs = crumbs
+	return
+}
+
+// parse returns the crumbs of the passed concatenated string, the offset being the position of this string in the crumbl (for error reporting)
+func parse(crumbsStr string, offset int, layout encrypter.Layout) (crumbs encrypter.Crumbs, err error) {
+	crumbs, err = encrypter.ParseCrumbs(crumbsStr, layout)
+	var malformed encrypter.ErrMalformedCrumb
+	if errors.As(err, &malformed) {
+		malformed.Offset += offset
+		err = malformed
+	}
+	return
+}
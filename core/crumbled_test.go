@@ -0,0 +1,192 @@
+package core_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+
+	"gotest.tools/assert"
+)
+
+// TestParse ...
+func TestParse(t *testing.T) {
+	crumbled := "580fb8a91f05833200dea7d33536aaec9d7ceb256a9858ee68e330e126ba409d0000a8BJM2I8mS/bkFNdZOATg8jHsQbzYp4o5rTqYWkf/pqgvkH7a4OijBxy86W1y2J+pB525jYO4iBuig2JswBdNv++8dkb0GcSXT873M0I5Xma9oM83eHXihOF2rqnqWN/RNZPwJSM23DcCj/xyVs1FK5jWVMGxtMLttIN7vqg==010158KbcQ6boXhkGdXR97+UwSHvt12wEwkVa57e+2m+66sTu32luP00cWET2gb01tgNZYjU621U7u4RI6fmz5kkyTSZtjPJ5wXISTf2wOBv5cY94LvgYoyMFKP9J3mGbPgAKGGsIdY4GCQBx6+Gi7VzfuNxdP1YHAPqcpKXPWiY+nmqYhT7eZVZlmNF1UmkMbgrneYglenmKxWSyUA6P7yMj3LrhlKekWAPdWpMLzRftLh1oH5e2KHkz7Wyh9eYOCKXlQ4sUUm8o3i0Inann41wL0KGaNajPU1RP0M9n3/Zil1/T+ZZcNJgSlQh1mxVKX1ztBRqYNUy+pqDat1qq6ED5r5A==0200a8BIIMyYgouCq7ZVy7S1kRJUl1Lg+aQMHoNeo7SauKwsy//XZ5rJOF4FrYMXmPpu0pf7nwCgAgk6Iv9IQK+WXsKpDE+QazdPpYFtxm4/1qi8qnzG1Wp/9Lf5nFTozacHqghz2e7XkaO1qyLNfmzimpsm6aw/lhEsd+djJ8KA==.1"
+	parsed, err := core.Parse(crumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, parsed.Version, "1")
+	assert.Equal(t, parsed.Hashered, crumbled[:crypto.DEFAULT_HASH_LENGTH])
+	assert.Equal(t, len(parsed.Crumbs), 3)
+	assert.Equal(t, parsed.Crumbs[0].Fingerprint, "")
+	assert.Equal(t, parsed.String(), crumbled)
+
+	c := core.Crumbl{
+		Source:     "cdever@edgewhere.fr",
+		HashEngine: crypto.DEFAULT_HASH_ENGINE,
+		Owners: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+				PublicKey:           owner1_pubkey,
+			},
+		},
+		Trustees: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.RSA_ALGORITHM,
+				PublicKey:           trustee2_pubkey,
+			},
+		},
+	}
+	crumbled, err = c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err = core.Parse(crumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, parsed.Version, core.VERSION)
+	assert.Equal(t, parsed.Crumbs.GetAt(0)[0].Fingerprint, "04624532")
+	assert.Equal(t, parsed.Crumbs.GetAt(1)[0].Fingerprint, "6affdc4d")
+	assert.Equal(t, parsed.String(), crumbled)
+
+	c.Version = "0"
+	_, err = c.Process()
+	assert.Assert(t, errors.Is(err, core.ErrVersionMismatch))
+}
+
+// TestParseTrailer ...
+func TestParseTrailer(t *testing.T) {
+	crumbled := "580fb8a91f05833200dea7d33536aaec9d7ceb256a9858ee68e330e126ba409d0004624532000cRWRnZXdoZXJl018586d76f000cRWRnZXdoZXJl.4.t:1"
+	parsed, err := core.Parse(crumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, parsed.Version, "4")
+	assert.Equal(t, parsed.Threshold, 1)
+	assert.Equal(t, len(parsed.Crumbs), 2)
+	assert.Equal(t, parsed.String(), crumbled)
+
+	_, err = core.Parse(strings.Replace(crumbled, ".4.", ".3.", 1))
+	assert.Error(t, err, "invalid crumbled string: unexpected field t:1 in version 3")
+	_, err = core.Parse(crumbled + ".t:2")
+	assert.Error(t, err, "invalid crumbled string: unexpected field t:2 in version 4")
+	_, err = core.Parse(strings.Replace(crumbled, "t:1", "t:0", 1))
+	assert.Error(t, err, "invalid crumbled string: invalid threshold 0")
+	_, err = core.Parse(crumbled + ".t")
+	assert.Error(t, err, "invalid crumbled string: malformed field t")
+
+	_, err = core.Parse(crumbled + ".h:sha-512")
+	assert.Error(t, err, "invalid crumbled string: unexpected field h:sha-512 in version 4")
+	_, err = core.Parse(strings.Replace(crumbled, ".4.", ".5.", 1) + ".h:md5")
+	assert.Error(t, err, "invalid hash engine: md5")
+	parsed, err = core.Parse(strings.Replace(crumbled, ".4.", ".5.", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, parsed.HashEngine, crypto.DEFAULT_HASH_ENGINE)
+}
+
+// TestCrumbledJSON ...
+func TestCrumbledJSON(t *testing.T) {
+	crumbled := "580fb8a91f05833200dea7d33536aaec9d7ceb256a9858ee68e330e126ba409d0004624532000cRWRnZXdoZXJl018586d76f000cRWRnZXdoZXJl.4.t:1"
+	parsed, err := core.Parse(crumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(data), `{"version":"4","hashered":"580fb8a91f05833200dea7d33536aaec9d7ceb256a9858ee68e330e126ba409d","crumbs":[{"index":0,"fingerprint":"04624532","length":12,"ciphertext":"RWRnZXdoZXJl"},{"index":1,"fingerprint":"8586d76f","length":12,"ciphertext":"RWRnZXdoZXJl"}],"threshold":1,"hashEngine":"sha-256"}`)
+	var unmarshalled core.Crumbled
+	err = json.Unmarshal(data, &unmarshalled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.DeepEqual(t, unmarshalled, parsed)
+
+	err = json.Unmarshal([]byte(strings.Replace(string(data), `"04624532"`, `"0462"`, 1)), &unmarshalled)
+	assert.Assert(t, err != nil)
+	err = json.Unmarshal([]byte(strings.Replace(string(data), `"version":"4"`, `"version":"3"`, 1)), &unmarshalled)
+	assert.Error(t, err, "invalid crumbled string: unexpected field t:1 in version 3")
+}
+
+// TestSignCrumbled ...
+func TestSignCrumbled(t *testing.T) {
+	emitter := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           emitter_pubkey,
+		PrivateKey:          emitter_privkey,
+	}
+	c := core.Crumbl{
+		Source: "cdever@edgewhere.fr",
+		Owners: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+				PublicKey:           owner1_pubkey,
+			},
+		},
+		Trustees: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+				PublicKey:           trustee1_pubkey,
+			},
+		},
+		Threshold: 1,
+		Emitter:   emitter,
+	}
+	crumbled, err := c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := core.Parse(crumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, _ := crypto.Fingerprint(emitter_pubkey, crypto.ECIES_ALGORITHM)
+	assert.Equal(t, parsed.Emitter, fingerprint)
+	assert.Assert(t, strings.Contains(crumbled, "."+core.VERSION+".t:1.s:"+fingerprint+":"))
+	assert.Equal(t, parsed.String(), crumbled)
+	emitters := []signer.Signer{{EncryptionAlgorithm: crypto.ECIES_ALGORITHM, PublicKey: emitter_pubkey}}
+	assert.NilError(t, parsed.Verify(emitters))
+	data, err := json.Marshal(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unmarshalled core.Crumbled
+	err = json.Unmarshal(data, &unmarshalled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NilError(t, unmarshalled.Verify(emitters))
+
+	// Forged, tampered with or unsigned crumbls are rejected
+	err = parsed.Verify([]signer.Signer{{EncryptionAlgorithm: crypto.ECIES_ALGORITHM, PublicKey: owner1_pubkey}})
+	assert.Assert(t, errors.Is(err, core.ErrUnknownEmitter))
+	tampered, err := core.Parse(strings.Replace(crumbled, ".t:1.", ".t:2.", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, errors.Is(tampered.Verify(emitters), crypto.ErrInvalidSignature))
+	unsigned := parsed
+	unsigned.Signature = ""
+	assert.Assert(t, errors.Is(unsigned.Verify(emitters), core.ErrUnsignedCrumbl))
+
+	// The signature is the last field as of version 7
+	_, err = core.Parse(crumbled + ".h:sha-512")
+	assert.ErrorContains(t, err, "invalid crumbled string: unexpected field s:"+fingerprint+":")
+	_, err = core.Parse(strings.Replace(crumbled, "."+core.VERSION+".", ".6.", 1))
+	assert.Assert(t, err != nil)
+	_, err = core.Parse(crumbled[:strings.LastIndex(crumbled, ":")])
+	assert.Error(t, err, "invalid crumbled string: malformed signature "+fingerprint)
+
+	c.Version = "6"
+	_, err = c.Process()
+	assert.Error(t, err, "signature not supported in version 6")
+}
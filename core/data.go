@@ -1,41 +1,184 @@
 package core
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/cyrildever/crumbl-exe/crypto"
 	"github.com/cyrildever/crumbl-exe/decrypter"
 	"github.com/cyrildever/crumbl-exe/encrypter"
+	"github.com/cyrildever/crumbl-exe/models/signer"
 )
 
-// GetCrumbs returns the underlying slices of the passed crumbled string
-func GetCrumbs(crumbled string) (crumbs []encrypter.Crumb, err error) {
-	parts := strings.SplitN(crumbled, ".", 2)
-	if parts[1] != VERSION {
-		err = errors.New("incompatible version: " + parts[1])
+//--- TYPES
+
+// PartialUncrumbs is the parsed representation of the partial uncrumbs returned when uncrumbling without all the necessary crumbs,
+// eg. by a trustee, ie. of a string made of:
+// - the verification hash of the crumbl;
+// - the concatenation of the stringified uncrumbs, each starting with decrypter.PARTIAL_PREFIX;
+// - a dot followed by the version of the crumbl;
+// - as of version 7, if signed, a dot followed by SIGNATURE_TAG, a colon, the fingerprint of the signer, a colon and the base64-encoded signature of all that precedes.
+type PartialUncrumbs struct {
+	VerificationHash string              `json:"verificationHash"`
+	Uncrumbs         []decrypter.Uncrumb `json:"uncrumbs"`
+	Version          string              `json:"version"`
+	Signer           string              `json:"signer,omitempty"`    // The fingerprint of the public key of the signer, usually a trustee
+	Signature        string              `json:"signature,omitempty"` // The base64-encoded signature of the content (see crypto.Sign)
+}
+
+//--- METHODS
+
+// String returns the partial uncrumbs string
+func (p PartialUncrumbs) String() string {
+	str := p.content()
+	if p.Signature != "" {
+		str += "." + SIGNATURE_TAG + ":" + p.Signer + ":" + p.Signature
+	}
+	return str
+}
+
+// Sign signs the partial uncrumbs with the private key of the passed signer, failing if their version doesn't support it
+func (p *PartialUncrumbs) Sign(s signer.Signer) error {
+	if !p.Signable() {
+		return fmt.Errorf("partial uncrumbs signature not supported in version %s", p.Version)
+	}
+	fingerprint, err := crypto.Fingerprint(s.PublicKey, s.EncryptionAlgorithm)
+	if err != nil {
+		return err
+	}
+	signature, err := crypto.Sign([]byte(p.content()), s.PrivateKey, s.EncryptionAlgorithm)
+	if err != nil {
+		return err
+	}
+	p.Signer = fingerprint
+	p.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// Verify checks that the partial uncrumbs are signed by any of the passed signers, and that this signer is the recipient of a crumb
+// of the passed crumbl at the index of each uncrumb. It returns ErrUnsignedPartialUncrumbs if they're not signed at all,
+// ErrUnknownSigner if signed by someone else, crypto.ErrInvalidSignature if they were tampered with, or ErrMisplacedUncrumb
+// if the signer holds no crumb at the index of any of them.
+func (p PartialUncrumbs) Verify(signers []signer.Signer, crumbs encrypter.Crumbs) error {
+	if p.Signature == "" {
+		return ErrUnsignedPartialUncrumbs
+	}
+	for _, s := range signers {
+		if fingerprint, e := crypto.Fingerprint(s.PublicKey, s.EncryptionAlgorithm); e != nil || fingerprint != p.Signer {
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(p.Signature)
+		if err != nil {
+			return crypto.ErrInvalidSignature
+		}
+		if err = crypto.Verify([]byte(p.content()), signature, s.PublicKey, s.EncryptionAlgorithm); err != nil {
+			return err
+		}
+		return p.checkIndices(crumbs)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownSigner, p.Signer)
+}
+
+// checkIndices checks that the signer of the partial uncrumbs holds a crumb of the passed ones at the index of each uncrumb
+func (p PartialUncrumbs) checkIndices(crumbs encrypter.Crumbs) error {
+	for _, uncrumb := range p.Uncrumbs {
+		found := false
+		for _, crumb := range crumbs.GetAt(uncrumb.Index) {
+			if crumb.Fingerprint == p.Signer {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: no crumb at index %d for %s", ErrMisplacedUncrumb, uncrumb.Index, p.Signer)
+		}
+	}
+	return nil
+}
+
+// Signable tells whether the version of the partial uncrumbs supports signing them, ie. whether they're expected to be signed
+func (p PartialUncrumbs) Signable() bool {
+	f, err := featuresOf(p.Version)
+	return err == nil && f.partialSignature
+}
+
+// content returns the signed part of the partial uncrumbs string
+func (p PartialUncrumbs) content() string {
+	str := p.VerificationHash
+	for _, uncrumb := range p.Uncrumbs {
+		str += uncrumb.String()
+	}
+	return str + "." + p.Version
+}
+
+//--- FUNCTIONS
+
+// ParsePartialUncrumbs returns the parsed representation of the passed partial uncrumbs string, or an error if any uncrumb is malformed
+func ParsePartialUncrumbs(partialUncrumbs string) (p PartialUncrumbs, err error) {
+	parts := strings.SplitN(partialUncrumbs, ".", 2)
+	if len(parts) != 2 {
+		err = errors.New("invalid partialUncrumb string: missing version")
+		return
+	}
+	fields := strings.Split(parts[1], ".")
+	f, err := featuresOf(fields[0])
+	if err != nil {
+		return
+	}
+	for _, field := range fields[1:] {
+		tagged := strings.Split(field, ":")
+		if !f.partialSignature || len(tagged) != 3 || tagged[0] != SIGNATURE_TAG || p.Signature != "" || tagged[1] == "" || tagged[2] == "" {
+			err = fmt.Errorf("invalid partialUncrumb string: unexpected field %s", field)
+			return
+		}
+		p.Signer = tagged[1]
+		p.Signature = tagged[2]
+	}
+	uncs := strings.Split(parts[0], decrypter.PARTIAL_PREFIX)
+	if len(uncs) < 2 || uncs[0] == "" {
+		err = errors.New("not a partialUncrumb string")
 		return
 	}
-	crumbsStr := parts[0][crypto.DEFAULT_HASH_LENGTH:]
-	cs, err := parse(crumbsStr)
+	p.VerificationHash = uncs[0]
+	p.Version = fields[0]
+	for _, unc := range uncs[1:] {
+		uncrumb, e := decrypter.ToUncrumb(unc)
+		if e != nil {
+			err = fmt.Errorf("invalid partialUncrumb string: %w", e)
+			return
+		}
+		p.Uncrumbs = append(p.Uncrumbs, uncrumb)
+	}
+	return
+}
+
+// GetCrumbs returns the underlying slices of the passed crumbled string
+func GetCrumbs(crumbled string) (crumbs []encrypter.Crumb, err error) {
+	c, err := Parse(crumbled)
 	if err != nil {
 		return
 	}
-	return cs, nil
+	return c.Crumbs, nil
 }
 
-// GetUncrumbs returns the underlying uncrumbs fromt the passed partialUncrumbs string
+// GetUncrumbs returns the underlying uncrumbs fromt the passed partialUncrumbs string, without checking its signature if any
 func GetUncrumbs(partialUncrumb string) (uncrumbs []decrypter.Uncrumb, err error) {
 	if !strings.Contains(partialUncrumb, decrypter.PARTIAL_PREFIX) {
 		err = errors.New("not a partialUncrumb string")
 		return
 	}
 	parts := strings.SplitN(partialUncrumb, ".", 2)
-	if parts[1] != VERSION {
-		err = errors.New("incompatible version: " + parts[1])
+	if len(parts) != 2 {
+		err = errors.New("invalid partialUncrumb string: missing version")
 		return
 	}
-	us := parts[0][crypto.DEFAULT_HASH_LENGTH:]
+	if version := strings.SplitN(parts[1], ".", 2)[0]; !IsSupportedVersion(version) {
+		err = fmt.Errorf("%w: %s", ErrVersionMismatch, version)
+		return
+	}
+	us := parts[0][strings.Index(parts[0], decrypter.PARTIAL_PREFIX):] // The verification hash length depends on the hash engine
 	uncs := strings.Split(us, decrypter.PARTIAL_PREFIX)
 	for _, unc := range uncs {
 		if unc != "" {
@@ -48,3 +191,16 @@ func GetUncrumbs(partialUncrumb string) (uncrumbs []decrypter.Uncrumb, err error
 	}
 	return
 }
+
+//--- ERRORS
+
+var (
+	// ErrUnsignedPartialUncrumbs is returned when partial uncrumbs expected to be signed are not
+	ErrUnsignedPartialUncrumbs = errors.New("unsigned partial uncrumbs")
+
+	// ErrUnknownSigner is returned when partial uncrumbs are signed by none of the expected signers
+	ErrUnknownSigner = errors.New("unknown signer of partial uncrumbs")
+
+	// ErrMisplacedUncrumb is returned when the signer of partial uncrumbs isn't the recipient of the crumb at the index of one of them
+	ErrMisplacedUncrumb = errors.New("uncrumb not intended for the signer of partial uncrumbs")
+)
@@ -1,9 +1,15 @@
 package core_test
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/encrypter"
+	"github.com/cyrildever/crumbl-exe/models/signer"
 
 	"gotest.tools/assert"
 )
@@ -16,6 +22,15 @@ func TestGetCrumbs(t *testing.T) {
 		t.Fatal(err)
 	}
 	assert.Equal(t, len(crumbs), 3)
+
+	_, err = core.GetCrumbs(strings.TrimSuffix(crumbled, ".1") + ".0")
+	assert.Assert(t, errors.Is(err, core.ErrVersionMismatch))
+	assert.Error(t, err, "incompatible version: 0")
+
+	_, err = core.GetCrumbs(strings.Replace(crumbled, "0000a8", "0000a9", 1))
+	var malformed encrypter.ErrMalformedCrumb
+	assert.Assert(t, errors.As(err, &malformed))
+	assert.Equal(t, malformed.Offset, 64+6)
 }
 
 // TestGetUncrumbs ...
@@ -28,3 +43,87 @@ func TestGetUncrumbs(t *testing.T) {
 	assert.Equal(t, len(uncrumbs), 1)
 	assert.Equal(t, uncrumbs[0].Index, 2)
 }
+
+// TestParsePartialUncrumbs ...
+func TestParsePartialUncrumbs(t *testing.T) {
+	partialUncrumbs := "580fb8a91f05833200dea7d33536aaec9d7ceb256a9858ee68e330e126ba409d%01AgIEVQMOTg9cRwk=%02AgICAgICAgkYUkI=.1"
+	parsed, err := core.ParsePartialUncrumbs(partialUncrumbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, parsed.VerificationHash, partialUncrumbs[:64])
+	assert.Equal(t, parsed.Version, "1")
+	assert.Equal(t, len(parsed.Uncrumbs), 2)
+	assert.Equal(t, parsed.String(), partialUncrumbs)
+
+	data, err := json.Marshal(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(data), `{"verificationHash":"580fb8a91f05833200dea7d33536aaec9d7ceb256a9858ee68e330e126ba409d","uncrumbs":[{"deciphered":"AgIEVQMOTg9cRwk=","index":1},{"deciphered":"AgICAgICAgkYUkI=","index":2}],"version":"1"}`)
+	var unmarshalled core.PartialUncrumbs
+	err = json.Unmarshal(data, &unmarshalled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, unmarshalled.String(), partialUncrumbs)
+
+	_, err = core.ParsePartialUncrumbs(strings.Replace(partialUncrumbs, "%02", "%zz", 1))
+	assert.Assert(t, err != nil)
+	_, err = core.ParsePartialUncrumbs(partialUncrumbs[:64] + ".1")
+	assert.Error(t, err, "not a partialUncrumb string")
+}
+
+// TestSignPartialUncrumbs ...
+func TestSignPartialUncrumbs(t *testing.T) {
+	trustee := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           trustee1_pubkey,
+		PrivateKey:          trustee1_privkey,
+	}
+
+	// Partial uncrumbs can't be signed before version 7
+	unsigned := "580fb8a91f05833200dea7d33536aaec9d7ceb256a9858ee68e330e126ba409d%01AgIEVQMOTg9cRwk=%02AgICAgICAgkYUkI=.6"
+	parsed, err := core.ParsePartialUncrumbs(unsigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, !parsed.Signable())
+	assert.Error(t, parsed.Sign(trustee), "partial uncrumbs signature not supported in version 6")
+	_, err = core.ParsePartialUncrumbs(unsigned + ".s:8586d76f:AA==")
+	assert.Error(t, err, "invalid partialUncrumb string: unexpected field s:8586d76f:AA==")
+
+	partialUncrumbs := "580fb8a91f05833200dea7d33536aaec9d7ceb256a9858ee68e330e126ba409d%01AgIEVQMOTg9cRwk=%02AgICAgICAgkYUkI=.7"
+	parsed, err = core.ParsePartialUncrumbs(partialUncrumbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, parsed.Signable())
+	crumbs := encrypter.Crumbs{{Index: 1, Fingerprint: "8586d76f"}, {Index: 2, Fingerprint: "8586d76f"}}
+	assert.Assert(t, errors.Is(parsed.Verify([]signer.Signer{trustee}, crumbs), core.ErrUnsignedPartialUncrumbs))
+	err = parsed.Sign(trustee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, parsed.Signer, "8586d76f")
+	signed := parsed.String()
+	assert.Assert(t, strings.HasPrefix(signed, partialUncrumbs+".s:8586d76f:"))
+	reparsed, err := core.ParsePartialUncrumbs(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.DeepEqual(t, reparsed, parsed)
+	assert.NilError(t, reparsed.Verify([]signer.Signer{trustee}, crumbs))
+
+	// The signer must hold a crumb at the index of each uncrumb
+	others := encrypter.Crumbs{crumbs[0], {Index: 2, Fingerprint: "0123abcd"}}
+	assert.Error(t, reparsed.Verify([]signer.Signer{trustee}, others), "uncrumb not intended for the signer of partial uncrumbs: no crumb at index 2 for 8586d76f")
+	uncrumbs, err := core.GetUncrumbs(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(uncrumbs), 2)
+
+	_, err = core.ParsePartialUncrumbs(signed + ".s:8586d76f:AA==")
+	assert.Error(t, err, "invalid partialUncrumb string: unexpected field s:8586d76f:AA==")
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/decrypter"
+	"github.com/cyrildever/crumbl-exe/encrypter"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+)
+
+//--- TYPES
+
+// Delegation adds owners to a crumbl without involving its trustees: an existing owner deciphers its own crumb, ie. the owners' slice,
+// and encrypts the same slice for each new owner, the hashered prefix being computed again for the new set of owners' crumbs.
+// The verification hash of the crumbl is left unchanged.
+type Delegation struct {
+	Crumbled  string
+	Owner     signer.Signer   // An existing owner of the crumbl, with its private key
+	NewOwners []signer.Signer // The owners to add, with their public key only
+	Emitter   signer.Signer   // Optional: as of version 7, the emitter signing the crumbl again, its former signature not matching anymore
+	Emitters  []signer.Signer // Optional: as of version 7, the authorized emitters, one of whom must have signed the crumbl
+}
+
+//--- METHODS
+
+// Process returns the crumbl with the crumbs of the new owners
+func (d *Delegation) Process() (string, error) {
+	return d.doDelegate()
+}
+
+// doDelegate deciphers the owner's crumb, encrypts it for the new owners and finalizes the crumbl again
+func (d *Delegation) doDelegate() (crumbled string, err error) {
+	if len(d.NewOwners) == 0 {
+		err = errors.New("no owner to add")
+		return
+	}
+
+	// 1- Parse
+	parsed, err := Parse(d.Crumbled)
+	if err != nil {
+		return
+	}
+	if len(d.Emitters) > 0 {
+		if err = parsed.Verify(d.Emitters); err != nil {
+			return
+		}
+	}
+	if parsed.Signature != "" && len(d.Emitter.PrivateKey) == 0 {
+		err = ErrSignatureNeeded
+		return
+	}
+	f, _ := featuresOf(parsed.Version)
+
+	// 2- Decrypt the owners' slice
+	fingerprint, err := crypto.Fingerprint(d.Owner.PublicKey, d.Owner.EncryptionAlgorithm)
+	if err != nil {
+		return
+	}
+	ownerCrumbs := parsed.Crumbs.GetAt(0)
+	var uncrumb decrypter.Uncrumb
+	found := false
+	for _, crumb := range ownerCrumbs {
+		if f.layout.Fingerprint && crumb.Fingerprint != fingerprint {
+			continue
+		}
+		if u, e := decrypter.Decrypt(crumb, d.Owner); e == nil {
+			uncrumb, found = u, true
+			break
+		}
+	}
+	if !found {
+		err = fmt.Errorf("%w: %s", ErrNotAnOwner, fingerprint)
+		return
+	}
+
+	// 3- Encrypt it for the new owners
+	owners := make(map[string]bool)
+	for _, crumb := range ownerCrumbs {
+		owners[crumb.Fingerprint] = true
+	}
+	var newCrumbs []encrypter.Crumb
+	for _, owner := range d.NewOwners {
+		crumb, e := encrypter.Encrypt(uncrumb.ToSlice(), 0, owner)
+		if e != nil {
+			err = e
+			return
+		}
+		if f.layout.Fingerprint && owners[crumb.Fingerprint] {
+			err = fmt.Errorf("%w: %s", ErrAlreadyAnOwner, crumb.Fingerprint)
+			return
+		}
+		owners[crumb.Fingerprint] = true
+		if !f.layout.Fits(crumb.Length) {
+			err = fmt.Errorf("%w: %d characters at index 0 in version %s", encrypter.ErrCrumbTooLong, crumb.Length, parsed.Version)
+			return
+		}
+		newCrumbs = append(newCrumbs, crumb)
+	}
+
+	// 4- Insert them after the existing owners' crumbs and finalize the crumbl again
+	crumbs := append(encrypter.Crumbs{}, ownerCrumbs...)
+	crumbs = append(crumbs, newCrumbs...)
+	for _, crumb := range parsed.Crumbs {
+		if crumb.Index != 0 {
+			crumbs = append(crumbs, crumb)
+		}
+	}
+	return parsed.withCrumbs(crumbs, d.Emitter)
+}
@@ -0,0 +1,101 @@
+package core_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+
+	"gotest.tools/assert"
+)
+
+// TestDelegation ...
+func TestDelegation(t *testing.T) {
+	source := "cdever@edgewhere.fr"
+	owner := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           owner1_pubkey,
+		PrivateKey:          owner1_privkey,
+	}
+	newOwner := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           trustee3_pubkey,
+		PrivateKey:          trustee3_privkey,
+	}
+	trustee := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           trustee1_pubkey,
+		PrivateKey:          trustee1_privkey,
+	}
+	emitter := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           emitter_pubkey,
+		PrivateKey:          emitter_privkey,
+	}
+	c := core.Crumbl{
+		Source:     source,
+		HashEngine: crypto.DEFAULT_HASH_ENGINE,
+		Owners:     []signer.Signer{{EncryptionAlgorithm: owner.EncryptionAlgorithm, PublicKey: owner.PublicKey}},
+		Trustees:   []signer.Signer{{EncryptionAlgorithm: trustee.EncryptionAlgorithm, PublicKey: trustee.PublicKey}},
+		Emitter:    emitter,
+	}
+	crumbled, err := c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verificationHash, _, _ := core.ExtractData(crumbled)
+
+	d := core.Delegation{
+		Crumbled:  crumbled,
+		Owner:     owner,
+		NewOwners: []signer.Signer{{EncryptionAlgorithm: newOwner.EncryptionAlgorithm, PublicKey: newOwner.PublicKey}},
+	}
+	_, err = d.Process()
+	assert.Assert(t, errors.Is(err, core.ErrSignatureNeeded))
+
+	d.Emitter = emitter
+	delegated, err := d.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := core.Parse(delegated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(parsed.Crumbs.GetAt(0)), 2)
+	assert.NilError(t, parsed.Verify([]signer.Signer{emitter}))
+	newVerificationHash, _, _ := core.ExtractData(delegated)
+	assert.Equal(t, newVerificationHash, verificationHash)
+
+	// The trustee's partial uncrumbs serve both owners
+	partial, err := (&core.Uncrumbl{Crumbled: delegated, Signer: trustee}).Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbs, err := core.GetUncrumbs(string(partial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []signer.Signer{owner, newOwner} {
+		uncrumbled, err := (&core.Uncrumbl{
+			Crumbled:         delegated,
+			Slices:           uncrumbs,
+			VerificationHash: verificationHash,
+			Signer:           o,
+			IsOwner:          true,
+		}).Process()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, string(uncrumbled), source)
+	}
+
+	d.Crumbled = delegated
+	_, err = d.Process()
+	assert.Assert(t, errors.Is(err, core.ErrAlreadyAnOwner))
+	d.Owner = trustee
+	_, err = d.Process()
+	assert.Assert(t, errors.Is(err, core.ErrNotAnOwner))
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cyrildever/crumbl-exe/crypto"
+)
+
+//--- TYPES
+
+// Description gives the structure of a crumbl without deciphering anything, eg. to know which stakeholders to contact for uncrumbling it.
+type Description struct {
+	Version        string             `json:"version"`
+	HashEngine     string             `json:"hashEngine"`
+	Hashered       string             `json:"hashered"`
+	NumberOfSlices int                `json:"numberOfSlices"`
+	Threshold      int                `json:"threshold,omitempty"`
+	ObfuscationKey string             `json:"obfuscationKey,omitempty"` // As of version 8, the ID of the obfuscation key if not the default one
+	Emitter        string             `json:"emitter,omitempty"`        // As of version 7, the fingerprint of the public key of the emitter who signed the crumbl, if any
+	Slices         []SliceDescription `json:"slices"`
+}
+
+// SliceDescription lists the crumbs found for a slice index, index 0 being reserved for the data owners.
+// With a threshold, each index above 0 holds a share of the trustees' slice for a single trustee.
+type SliceDescription struct {
+	Index  int                `json:"index"`
+	Crumbs []CrumbDescription `json:"crumbs"`
+}
+
+// CrumbDescription gives the fingerprint of the recipient's public key (as of version 2), the length of an encrypted crumb
+// and the encryption algorithm that was most likely used (empty if unknown).
+type CrumbDescription struct {
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Length      int    `json:"length"`
+	Size        int    `json:"size"`
+	Algorithm   string `json:"algorithm,omitempty"`
+}
+
+//--- METHODS
+
+// JSON returns the JSON representation of the description
+func (d Description) JSON() (string, error) {
+	bytes, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// String returns a human-readable representation of the description
+func (d Description) String() string {
+	var lines []string
+	lines = append(lines, "version: "+d.Version)
+	lines = append(lines, "hash engine: "+d.HashEngine)
+	lines = append(lines, "hashered: "+d.Hashered)
+	lines = append(lines, fmt.Sprintf("slices: %d", d.NumberOfSlices))
+	if d.Threshold > 0 {
+		lines = append(lines, fmt.Sprintf("threshold: %d trustee(s) needed", d.Threshold))
+	}
+	if d.ObfuscationKey != "" {
+		lines = append(lines, "obfuscation key: "+d.ObfuscationKey)
+	}
+	if d.Emitter != "" {
+		lines = append(lines, "signed by emitter: "+d.Emitter)
+	}
+	for _, slice := range d.Slices {
+		owner := ""
+		if slice.Index == 0 {
+			owner = " (owners)"
+		}
+		lines = append(lines, fmt.Sprintf("slice #%d%s: %d crumb(s)", slice.Index, owner, len(slice.Crumbs)))
+		for _, crumb := range slice.Crumbs {
+			algo := crumb.Algorithm
+			if algo == "" {
+				algo = "unknown algorithm"
+			}
+			recipient := ""
+			if crumb.Fingerprint != "" {
+				recipient = crumb.Fingerprint + ": "
+			}
+			lines = append(lines, fmt.Sprintf("  - %s%d characters (%d bytes), %s", recipient, crumb.Length, crumb.Size, algo))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+//--- FUNCTIONS
+
+// Inspect describes the passed crumbl without needing any key, hence without checking the signature of its emitter if any (see Crumbled.Verify)
+func Inspect(crumbled string) (d Description, err error) {
+	parsed, err := Parse(crumbled)
+	if err != nil {
+		return
+	}
+	slices := make(map[int][]CrumbDescription)
+	for _, crumb := range parsed.Crumbs {
+		ciphertext := crumb.Encrypted.Bytes()
+		slices[crumb.Index] = append(slices[crumb.Index], CrumbDescription{
+			Fingerprint: crumb.Fingerprint,
+			Length:      crumb.Length,
+			Size:        len(ciphertext),
+			Algorithm:   crypto.GuessAlgorithm(ciphertext),
+		})
+	}
+	d = Description{
+		Version:        parsed.Version,
+		HashEngine:     parsed.HashEngine,
+		Hashered:       parsed.Hashered,
+		NumberOfSlices: len(slices),
+		Threshold:      parsed.Threshold,
+		ObfuscationKey: parsed.ObfuscationKey,
+		Emitter:        parsed.Emitter,
+	}
+	for index, crumbs := range slices {
+		d.Slices = append(d.Slices, SliceDescription{
+			Index:  index,
+			Crumbs: crumbs,
+		})
+	}
+	sort.Slice(d.Slices, func(i, j int) bool {
+		return d.Slices[i].Index < d.Slices[j].Index
+	})
+	return
+}
@@ -0,0 +1,71 @@
+package core_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+	"gotest.tools/assert"
+)
+
+// TestInspect ...
+func TestInspect(t *testing.T) {
+	c := core.Crumbl{
+		Source:     "cdever@edgewhere.fr",
+		HashEngine: crypto.DEFAULT_HASH_ENGINE,
+		Owners: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+				PublicKey:           owner1_pubkey,
+			},
+		},
+		Trustees: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+				PublicKey:           trustee1_pubkey,
+			},
+			{
+				EncryptionAlgorithm: crypto.RSA_ALGORITHM,
+				PublicKey:           trustee2_pubkey,
+			},
+		},
+	}
+	crumbled, err := c.Process()
+	assert.NilError(t, err)
+
+	description, err := core.Inspect(crumbled)
+	assert.NilError(t, err)
+	assert.Equal(t, description.Version, core.VERSION)
+	assert.Equal(t, description.Hashered, crumbled[:crypto.DEFAULT_HASH_LENGTH])
+	assert.Equal(t, description.NumberOfSlices, 3)
+	assert.Equal(t, len(description.Slices), 3)
+	for i, slice := range description.Slices {
+		assert.Equal(t, slice.Index, i)
+		assert.Equal(t, len(slice.Crumbs), 1)
+	}
+	assert.Equal(t, description.Slices[0].Crumbs[0].Algorithm, crypto.ECIES_ALGORITHM)
+	algorithms := description.Slices[1].Crumbs[0].Algorithm + "," + description.Slices[2].Crumbs[0].Algorithm
+	assert.Assert(t, algorithms == "ecies,rsa" || algorithms == "rsa,ecies")
+	for _, slice := range description.Slices {
+		if slice.Crumbs[0].Algorithm == crypto.RSA_ALGORITHM {
+			assert.Equal(t, slice.Crumbs[0].Size, 256)
+		}
+	}
+
+	text := description.String()
+	assert.Assert(t, strings.HasPrefix(text, "version: "+core.VERSION+"\n"))
+	assert.Assert(t, strings.Contains(text, "slice #0 (owners): 1 crumb(s)"))
+
+	str, err := description.JSON()
+	assert.NilError(t, err)
+	var decoded core.Description
+	err = json.Unmarshal([]byte(str), &decoded)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, decoded, description)
+
+	_, err = core.Inspect("not a crumbl")
+	assert.Assert(t, err != nil)
+}
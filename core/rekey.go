@@ -0,0 +1,147 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/decrypter"
+	"github.com/cyrildever/crumbl-exe/hasher"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+	"github.com/cyrildever/crumbl-exe/obfuscator"
+	"github.com/cyrildever/crumbl-exe/utils"
+)
+
+//--- TYPES
+
+// Rekey crumbles again the source of a crumbl for new stakeholders, eg. when a trustee is decommissioned or an owner key is rotated.
+// The source is recovered by the owner from the crumbl and the partial uncrumbs of its trustees, then crumbled again with the
+// same hash engine, so that the new crumbl keeps the same verification hash, and checked by uncrumbling the new crumbl. It only ever lives in memory.
+type Rekey struct {
+	Uncrumbl Uncrumbl // The crumbl to rekey as seen by its owner, ie. with the owner as signer and the partial uncrumbs of the trustees as slices
+	Target   Crumbl   // The new stakeholders and options, the source and the hash engine being those of the crumbl to rekey
+}
+
+//--- METHODS
+
+// Process returns the new crumbl of the source of the crumbl to rekey
+func (r *Rekey) Process() (string, error) {
+	return r.doRekey()
+}
+
+// doRekey recovers the source, checks it against the verification hash of the crumbl and crumbles it again for the target stakeholders.
+// The new crumbl is then parsed back and uncrumbled in memory with the clear slices just encrypted for its trustees, the rekeying owner
+// deciphering its own crumb if it's among the new owners, so that it's only returned if it holds the same verification hash and gives
+// back the source. Otherwise, it fails with ErrInvalidRekeyedCrumbl.
+func (r *Rekey) doRekey() (crumbled string, err error) {
+	if !r.Uncrumbl.IsOwner {
+		err = errors.New("only the owner may rekey a crumbl")
+		return
+	}
+
+	// 1- Recover the source
+	parsed, err := Parse(r.Uncrumbl.Crumbled)
+	if err != nil {
+		return
+	}
+	verificationHash, _, err := ExtractData(r.Uncrumbl.Crumbled)
+	if err != nil {
+		return
+	}
+	source, err := r.Uncrumbl.Process()
+	if err != nil {
+		return
+	}
+	// 2- Check it's the source and not only partial uncrumbs
+	hash, err := crypto.Hash(source, parsed.HashEngine)
+	if err != nil {
+		return
+	}
+	if utils.ToHex(hash) != verificationHash {
+		err = ErrIncompleteUncrumbs
+		return
+	}
+
+	// 3- Crumble it again
+	c := r.Target
+	c.Source = string(source)
+	c.HashEngine = parsed.HashEngine
+	output, slices, err := c.crumble()
+	if err != nil {
+		return
+	}
+	rekeyed := output.String()
+
+	// 4- Check the new crumbl, ie. that its crumbs round-trip and that it uncrumbles to the source
+	reparsed, err := Parse(rekeyed)
+	if err != nil {
+		return
+	}
+	if len(reparsed.Crumbs) != len(output.Crumbs) {
+		err = fmt.Errorf("%w: %d crumbs instead of %d", ErrInvalidRekeyedCrumbl, len(reparsed.Crumbs), len(output.Crumbs))
+		return
+	}
+	for i, crumb := range reparsed.Crumbs {
+		if crumb != output.Crumbs[i] {
+			err = fmt.Errorf("%w: altered crumb at index %d", ErrInvalidRekeyedCrumbl, crumb.Index)
+			return
+		}
+	}
+	newVerificationHash, err := hasher.Unapply(reparsed.Hashered, reparsed.Crumbs)
+	if err != nil {
+		return
+	}
+	if newVerificationHash != verificationHash {
+		err = ErrVerificationHashMismatch
+		return
+	}
+	isNewOwner, err := r.isNewOwner()
+	if err != nil {
+		return
+	}
+	var uncrumbs []decrypter.Uncrumb
+	for _, uncrumb := range slices {
+		if uncrumb.Index == 0 && isNewOwner {
+			// The rekeying owner must decipher its own crumb
+			continue
+		}
+		uncrumbs = append(uncrumbs, uncrumb)
+	}
+	check := Uncrumbl{
+		Crumbled:         rekeyed,
+		Slices:           uncrumbs,
+		VerificationHash: verificationHash,
+		Signer:           r.Uncrumbl.Signer,
+		IsOwner:          true,
+		ObfuscationKeys:  []obfuscator.Key{c.ObfuscationKey},
+	}
+	if len(c.Emitter.PrivateKey) > 0 {
+		check.Emitters = []signer.Signer{c.Emitter}
+	}
+	uncrumbled, err := check.Process()
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrInvalidRekeyedCrumbl, err)
+		return
+	}
+	if !bytes.Equal(uncrumbled, source) {
+		err = fmt.Errorf("%w: not uncrumbled to the source", ErrInvalidRekeyedCrumbl)
+		return
+	}
+	crumbled = rekeyed
+	return
+}
+
+// isNewOwner tells whether the rekeying owner is among the owners of the new crumbl
+func (r *Rekey) isNewOwner() (bool, error) {
+	fingerprint, err := crypto.Fingerprint(r.Uncrumbl.Signer.PublicKey, r.Uncrumbl.Signer.EncryptionAlgorithm)
+	if err != nil {
+		return false, err
+	}
+	for _, owner := range r.Target.Owners {
+		if f, e := crypto.Fingerprint(owner.PublicKey, owner.EncryptionAlgorithm); e == nil && f == fingerprint {
+			return true, nil
+		}
+	}
+	return false, nil
+}
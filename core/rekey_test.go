@@ -0,0 +1,158 @@
+package core_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+	"github.com/cyrildever/crumbl-exe/obfuscator"
+
+	"gotest.tools/assert"
+)
+
+// TestRekey ...
+func TestRekey(t *testing.T) {
+	source := "cdever@edgewhere.fr"
+	owner := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           owner1_pubkey,
+		PrivateKey:          owner1_privkey,
+	}
+	oldTrustee := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           trustee1_pubkey,
+		PrivateKey:          trustee1_privkey,
+	}
+	newTrustee := signer.Signer{
+		EncryptionAlgorithm: crypto.RSA_ALGORITHM,
+		PublicKey:           trustee2_pubkey,
+		PrivateKey:          trustee2_privkey,
+	}
+	c := core.Crumbl{
+		Source:     source,
+		HashEngine: crypto.SHA3_HASH_ENGINE,
+		Owners:     []signer.Signer{{EncryptionAlgorithm: owner.EncryptionAlgorithm, PublicKey: owner.PublicKey}},
+		Trustees:   []signer.Signer{{EncryptionAlgorithm: oldTrustee.EncryptionAlgorithm, PublicKey: oldTrustee.PublicKey}},
+		Version:    "7",
+	}
+	crumbled, err := c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verificationHash, _, _ := core.ExtractData(crumbled)
+	partial, err := (&core.Uncrumbl{Crumbled: crumbled, Signer: oldTrustee}).Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbs, err := core.GetUncrumbs(string(partial))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the partial uncrumbs of the trustee, the source can't be recovered
+	rekey := core.Rekey{
+		Uncrumbl: core.Uncrumbl{
+			Crumbled: crumbled,
+			Signer:   owner,
+			IsOwner:  true,
+		},
+		Target: core.Crumbl{
+			Owners:         c.Owners,
+			Trustees:       []signer.Signer{{EncryptionAlgorithm: newTrustee.EncryptionAlgorithm, PublicKey: newTrustee.PublicKey}},
+			ObfuscationKey: obfuscator.Key{HashEngine: obfuscator.DEFAULT_HASH_ENGINE, Key: "tenant", Rounds: 12},
+		},
+	}
+	_, err = rekey.Process()
+	assert.Assert(t, errors.Is(err, core.ErrIncompleteUncrumbs))
+
+	rekey.Uncrumbl.Slices = uncrumbs
+	rekeyed, err := rekey.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := core.Parse(rekeyed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, parsed.Version, core.VERSION)
+	assert.Equal(t, parsed.HashEngine, crypto.SHA3_HASH_ENGINE)
+	newVerificationHash, _, _ := core.ExtractData(rekeyed)
+	assert.Equal(t, newVerificationHash, verificationHash)
+
+	// Only the new trustee may now help the owner
+	_, err = (&core.Uncrumbl{Crumbled: rekeyed, Signer: oldTrustee}).Process()
+	assert.Assert(t, errors.Is(err, core.ErrNotATrustee))
+	partial, err = (&core.Uncrumbl{Crumbled: rekeyed, Signer: newTrustee}).Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbs, err = core.GetUncrumbs(string(partial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbled, err := (&core.Uncrumbl{
+		Crumbled:         rekeyed,
+		Slices:           uncrumbs,
+		VerificationHash: verificationHash,
+		Signer:           owner,
+		IsOwner:          true,
+		ObfuscationKeys:  []obfuscator.Key{rekey.Target.ObfuscationKey},
+	}).Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(uncrumbled), source)
+
+	// The new crumbl is uncrumbled before being returned, eg. failing if its signature doesn't verify
+	rekey.Target.Emitter = signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           emitter_pubkey,
+		PrivateKey:          owner1_privkey,
+	}
+	_, err = rekey.Process()
+	assert.Assert(t, errors.Is(err, core.ErrInvalidRekeyedCrumbl))
+
+	// When rotating the owner key, the new owner alone can decipher the owner's crumb
+	newOwner := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           trustee3_pubkey,
+		PrivateKey:          trustee3_privkey,
+	}
+	rekey.Target.Emitter = signer.Signer{}
+	rekey.Target.Owners = []signer.Signer{{EncryptionAlgorithm: newOwner.EncryptionAlgorithm, PublicKey: newOwner.PublicKey}}
+	rekeyed, err = rekey.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial, err = (&core.Uncrumbl{Crumbled: rekeyed, Signer: newTrustee}).Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbs, err = core.GetUncrumbs(string(partial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbled, err = (&core.Uncrumbl{Crumbled: rekeyed, Slices: uncrumbs, Signer: owner, IsOwner: true}).Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, string(uncrumbled) != source)
+	uncrumbled, err = (&core.Uncrumbl{
+		Crumbled:         rekeyed,
+		Slices:           uncrumbs,
+		VerificationHash: verificationHash,
+		Signer:           newOwner,
+		IsOwner:          true,
+		ObfuscationKeys:  []obfuscator.Key{rekey.Target.ObfuscationKey},
+	}).Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(uncrumbled), source)
+
+	rekey.Uncrumbl.IsOwner = false
+	_, err = rekey.Process()
+	assert.Error(t, err, "only the owner may rekey a crumbl")
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/encrypter"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+)
+
+//--- TYPES
+
+// Revocation removes owners from a crumbl, eg. when an employee leaves or a key is compromised, by dropping their crumb of the owners' slice,
+// the hashered prefix being computed again for the remaining owners' crumbs. No private key is needed but the emitter's for a signed crumbl.
+// As the crumbs must tell the fingerprint of their owner, it requires version 2 at least.
+// The verification hash of the crumbl is left unchanged.
+type Revocation struct {
+	Crumbled string
+	Owners   []signer.Signer // The owners to remove, with their public key only
+	Emitter  signer.Signer   // Optional: as of version 7, the emitter signing the crumbl again, its former signature not matching anymore
+	Emitters []signer.Signer // Optional: as of version 7, the authorized emitters, one of whom must have signed the crumbl
+}
+
+//--- METHODS
+
+// Process returns the crumbl without the crumbs of the revoked owners
+func (r *Revocation) Process() (string, error) {
+	return r.doRevoke()
+}
+
+// doRevoke drops the crumbs of the revoked owners and finalizes the crumbl again
+func (r *Revocation) doRevoke() (crumbled string, err error) {
+	if len(r.Owners) == 0 {
+		err = errors.New("no owner to revoke")
+		return
+	}
+
+	// 1- Parse
+	parsed, err := Parse(r.Crumbled)
+	if err != nil {
+		return
+	}
+	if len(r.Emitters) > 0 {
+		if err = parsed.Verify(r.Emitters); err != nil {
+			return
+		}
+	}
+	if parsed.Signature != "" && len(r.Emitter.PrivateKey) == 0 {
+		err = ErrSignatureNeeded
+		return
+	}
+	if f, _ := featuresOf(parsed.Version); !f.layout.Fingerprint {
+		err = fmt.Errorf("owner revocation not supported in version %s", parsed.Version)
+		return
+	}
+
+	// 2- Drop the crumbs of the revoked owners
+	var fingerprints []string
+	revoked := make(map[string]bool)
+	for _, owner := range r.Owners {
+		fingerprint, e := crypto.Fingerprint(owner.PublicKey, owner.EncryptionAlgorithm)
+		if e != nil {
+			err = e
+			return
+		}
+		fingerprints = append(fingerprints, fingerprint)
+		revoked[fingerprint] = false
+	}
+	var crumbs encrypter.Crumbs
+	remainingOwners := 0
+	for _, crumb := range parsed.Crumbs {
+		if crumb.Index == 0 {
+			if _, found := revoked[crumb.Fingerprint]; found {
+				revoked[crumb.Fingerprint] = true
+				continue
+			}
+			remainingOwners++
+		}
+		crumbs = append(crumbs, crumb)
+	}
+	for _, fingerprint := range fingerprints {
+		if !revoked[fingerprint] {
+			err = fmt.Errorf("%w: %s", ErrNotAnOwner, fingerprint)
+			return
+		}
+	}
+	if remainingOwners == 0 {
+		err = ErrNoOwnerLeft
+		return
+	}
+
+	// 3- Finalize the crumbl again
+	return parsed.withCrumbs(crumbs, r.Emitter)
+}
@@ -0,0 +1,100 @@
+package core_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+
+	"gotest.tools/assert"
+)
+
+// TestRevocation ...
+func TestRevocation(t *testing.T) {
+	source := "cdever@edgewhere.fr"
+	owner := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           owner1_pubkey,
+		PrivateKey:          owner1_privkey,
+	}
+	leaving := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           trustee3_pubkey,
+		PrivateKey:          trustee3_privkey,
+	}
+	trustee := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           trustee1_pubkey,
+		PrivateKey:          trustee1_privkey,
+	}
+	c := core.Crumbl{
+		Source:     source,
+		HashEngine: crypto.DEFAULT_HASH_ENGINE,
+		Owners: []signer.Signer{
+			{EncryptionAlgorithm: owner.EncryptionAlgorithm, PublicKey: owner.PublicKey},
+			{EncryptionAlgorithm: leaving.EncryptionAlgorithm, PublicKey: leaving.PublicKey},
+		},
+		Trustees: []signer.Signer{{EncryptionAlgorithm: trustee.EncryptionAlgorithm, PublicKey: trustee.PublicKey}},
+	}
+	crumbled, err := c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verificationHash, _, _ := core.ExtractData(crumbled)
+
+	r := core.Revocation{
+		Crumbled: crumbled,
+		Owners:   []signer.Signer{{EncryptionAlgorithm: leaving.EncryptionAlgorithm, PublicKey: leaving.PublicKey}},
+	}
+	revoked, err := r.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := core.Parse(revoked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(parsed.Crumbs.GetAt(0)), 1)
+	newVerificationHash, _, _ := core.ExtractData(revoked)
+	assert.Equal(t, newVerificationHash, verificationHash)
+
+	// Only the remaining owner may still recover the source
+	partial, err := (&core.Uncrumbl{Crumbled: revoked, Signer: trustee}).Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbs, err := core.GetUncrumbs(string(partial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uOwner := core.Uncrumbl{
+		Crumbled:         revoked,
+		Slices:           uncrumbs,
+		VerificationHash: verificationHash,
+		Signer:           owner,
+		IsOwner:          true,
+	}
+	uncrumbled, err := uOwner.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(uncrumbled), source)
+	uOwner.Signer = leaving
+	uncrumbled, _ = uOwner.Process()
+	assert.Assert(t, string(uncrumbled) != source)
+
+	r.Crumbled = revoked
+	_, err = r.Process()
+	assert.Assert(t, errors.Is(err, core.ErrNotAnOwner))
+	r.Owners = []signer.Signer{{EncryptionAlgorithm: owner.EncryptionAlgorithm, PublicKey: owner.PublicKey}}
+	_, err = r.Process()
+	assert.Assert(t, errors.Is(err, core.ErrNoOwnerLeft))
+
+	c.Version = "1"
+	v1, _ := c.Process()
+	r.Crumbled = v1
+	_, err = r.Process()
+	assert.Error(t, err, "owner revocation not supported in version 1")
+}
@@ -3,7 +3,9 @@ package core
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/cyrildever/crumbl-exe/crypto"
@@ -12,8 +14,6 @@ import (
 	"github.com/cyrildever/crumbl-exe/hasher"
 	"github.com/cyrildever/crumbl-exe/models/signer"
 	"github.com/cyrildever/crumbl-exe/obfuscator"
-	"github.com/cyrildever/crumbl-exe/utils"
-	"github.com/cyrildever/feistel"
 )
 
 //--- TYPES
@@ -25,6 +25,9 @@ type Uncrumbl struct {
 	VerificationHash string
 	Signer           signer.Signer
 	IsOwner          bool
+	Emitters         []signer.Signer  // Optional: as of version 7, the authorized emitters, one of whom must have signed the crumbl
+	ObfuscationKeys  []obfuscator.Key // Optional: as of version 8, the keys the crumbl may be obfuscated with besides the default one
+	Diagnostics      io.Writer        // Optional destination of warnings
 }
 
 //--- METHODS
@@ -59,7 +62,6 @@ func (u Uncrumbl) ToFile(filename string) (result string, err error) {
 		err = e
 		return
 	}
-	fmt.Printf("SUCCESS - result saved in %v\n", filename)
 	result = string(uncrumbled)
 	return
 }
@@ -90,12 +92,30 @@ func (u *Uncrumbl) ToStdOut() (result string, err error) {
 // the verification hash being prefixed for tracking purpose, and the version at the end after a dot.
 func (u *Uncrumbl) doUncrumbl() (uncrumbled []byte, err error) {
 	// 1- Parse
-	verificationHash, crumbs, err := ExtractData(u.Crumbled)
+	parsed, err := Parse(u.Crumbled)
+	if err != nil {
+		return
+	}
+	if len(u.Emitters) > 0 {
+		if err = parsed.Verify(u.Emitters); err != nil {
+			return
+		}
+	}
+	crumbs := parsed.Crumbs
+	verificationHash, err := hasher.Unapply(parsed.Hashered, crumbs)
 	if err != nil {
 		return
 	}
 	if u.VerificationHash != verificationHash {
-		fmt.Fprintln(os.Stderr, "WARNING - incompatible input verification hash with crumbl", "u.VerificationHash", u.VerificationHash, "verificationHash", verificationHash)
+		u.warning("incompatible input verification hash with crumbl", "u.VerificationHash", u.VerificationHash, "verificationHash", verificationHash)
+	}
+	f, _ := featuresOf(parsed.Version)
+	var fingerprint string
+	if f.layout.Fingerprint {
+		fingerprint, err = crypto.Fingerprint(u.Signer.PublicKey, u.Signer.EncryptionAlgorithm)
+		if err != nil {
+			return
+		}
 	}
 
 	// 2- Decrypt crumbs
@@ -109,14 +129,24 @@ func (u *Uncrumbl) doUncrumbl() (uncrumbled []byte, err error) {
 		if (!u.IsOwner && idx == 0) || (u.IsOwner && idx != 0) {
 			continue
 		}
+		if fingerprint != "" && crumb.Fingerprint != fingerprint {
+			// Only try the crumbs intended for the signer when the crumbl tells so
+			continue
+		}
 		uncrumb, e := decrypter.Decrypt(crumb, u.Signer)
 		if e == nil {
 			if _, found := uncrumbs[uncrumb.Index]; !found {
 				uncrumbs[uncrumb.Index] = uncrumb
 			}
+		} else if fingerprint != "" {
+			u.warning(fmt.Sprintf("unable to decrypt crumb intended for signer at index %d: %v", idx, e))
 		}
 	}
 
+	if fingerprint != "" && len(uncrumbs) == 0 && u.IsOwner {
+		u.warning("no crumb intended for signer " + fingerprint)
+	}
+
 	// 3- Add passed uncrumbs
 	for _, uncrumb := range u.Slices {
 		if _, found := uncrumbs[uncrumb.Index]; !found {
@@ -126,19 +156,38 @@ func (u *Uncrumbl) doUncrumbl() (uncrumbled []byte, err error) {
 
 	// 4- Determine output
 	hasAllUncrumbs := false
-	if len(indexSet) == len(uncrumbs) {
+	_, hasOwnerUncrumb := uncrumbs[0]
+	shares := len(uncrumbs)
+	if hasOwnerUncrumb {
+		shares--
+	}
+	if parsed.Threshold > 0 {
+		// Only the owners' slice and enough shares of the trustees' slice are needed
+		hasAllUncrumbs = hasOwnerUncrumb && shares >= parsed.Threshold
+	} else if len(indexSet) == len(uncrumbs) {
 		hasAllUncrumbs = true
 	}
 	if u.IsOwner && !hasAllUncrumbs {
-		fmt.Fprintln(os.Stderr, "WARNING - missing crumbs to fully uncrumbl as data owner: only partial uncrumbs to be returned")
+		u.warning("missing crumbs to fully uncrumbl as data owner: only partial uncrumbs to be returned")
+		if parsed.Threshold > 0 {
+			if parsed.Threshold > shares {
+				u.warning(fmt.Sprintf("missing %d more partial uncrumb(s) from any of trustees %s", parsed.Threshold-shares, strings.Join(missingTrustees(crumbs, uncrumbs), ", ")))
+			}
+		} else if fingerprint != "" {
+			for _, missing := range missingTrustees(crumbs, uncrumbs) {
+				u.warning("missing partial uncrumb from trustee " + missing)
+			}
+		}
 	}
+
 	if hasAllUncrumbs {
 		// Owner may recover fully-deciphered data
 		collector := decrypter.Collector{
 			Map:              uncrumbs,
 			NumberOfSlices:   len(indexSet),
 			VerificationHash: verificationHash,
-			HashEngine:       crypto.DEFAULT_HASH_ENGINE,
+			HashEngine:       parsed.HashEngine,
+			Threshold:        parsed.Threshold,
 		}
 
 		// 5a- Deofbuscate
@@ -147,8 +196,17 @@ func (u *Uncrumbl) doUncrumbl() (uncrumbled []byte, err error) {
 			err = e
 			return
 		}
-		obfuscator := obfuscator.NewObfuscator(feistel.NewFPECipher(obfuscator.DEFAULT_HASH_ENGINE, obfuscator.DEFAULT_KEY_STRING, obfuscator.DEFAULT_ROUNDS))
-		deobfuscated, e := obfuscator.Unapply(obfuscated)
+		key, e := u.obfuscationKey(parsed.ObfuscationKey)
+		if e != nil {
+			err = e
+			return
+		}
+		o, e := obfuscator.NewObfuscatorWith(key)
+		if e != nil {
+			err = e
+			return
+		}
+		deobfuscated, e := o.Unapply(obfuscated)
 		if e != nil {
 			err = e
 			return
@@ -156,66 +214,94 @@ func (u *Uncrumbl) doUncrumbl() (uncrumbled []byte, err error) {
 
 		// 6a- Check
 		if !collector.Check([]byte(deobfuscated)) {
-			err = errors.New("source has not checked verification hash")
+			err = ErrVerificationHashMismatch
 			return
 		}
 
 		// 7a- Return uncrumbled data, ie. original source normally
 		uncrumbled = []byte(deobfuscated)
 	} else {
-		// Trustee may only return his own uncrumbs
+		// Trustee may only return his own uncrumbs, if any
+		if !u.IsOwner && len(uncrumbs) == 0 {
+			err = ErrNotATrustee
+			return
+		}
 
 		// 5b- Build partial uncrumbs
-		var partialUncrumbs string
+		partialUncrumbs := PartialUncrumbs{
+			VerificationHash: verificationHash,
+			Version:          parsed.Version,
+		}
 		for _, uncrumb := range uncrumbs {
-			partialUncrumbs += uncrumb.String()
+			partialUncrumbs.Uncrumbs = append(partialUncrumbs.Uncrumbs, uncrumb)
 		}
+		sort.Slice(partialUncrumbs.Uncrumbs, func(i, j int) bool {
+			return partialUncrumbs.Uncrumbs[i].Index < partialUncrumbs.Uncrumbs[j].Index
+		})
 
-		// 6b- Add verification hash prefix
-		uncrumbled = []byte(verificationHash + partialUncrumbs + "." + VERSION)
+		// 6b- As of version 7, sign them so that the owner may authenticate their origin
+		if f.partialSignature {
+			if e := partialUncrumbs.Sign(u.Signer); e != nil {
+				err = e
+				return
+			}
+		}
+		uncrumbled = []byte(partialUncrumbs.String())
 	}
 
 	return
 }
 
-// ExtractData ...
-func ExtractData(crumbled string) (verificationHash string, crumbs encrypter.Crumbs, err error) {
-	parts := strings.SplitN(crumbled, ".", 2)
-	if parts[1] != VERSION {
-		err = errors.New("incompatible version: " + parts[1])
-		return
-	}
-
-	crumbsStr := parts[0][crypto.DEFAULT_HASH_LENGTH:]
-	crms, err := parse(crumbsStr)
-	if err != nil {
-		return
+// obfuscationKey returns the obfuscation key with the passed ID among the keys of the uncrumbler, the default key if the ID is empty
+func (u *Uncrumbl) obfuscationKey(id string) (obfuscator.Key, error) {
+	if id == "" {
+		return obfuscator.DefaultKey(), nil
 	}
-
-	hashered := parts[0][0:crypto.DEFAULT_HASH_LENGTH]
-	vh, err := hasher.Unapply(hashered, crms)
-	if err != nil {
-		return
+	for _, key := range u.ObfuscationKeys {
+		if key.ID() == id {
+			return key, nil
+		}
 	}
-
-	return vh, crms, nil
+	return obfuscator.Key{}, fmt.Errorf("%w: %s", ErrUnknownObfuscationKey, id)
 }
 
-func parse(crumbsStr string) (crumbs encrypter.Crumbs, err error) {
-	for len(crumbsStr) > 7 {
-		nextLen, e := utils.HexToInt(crumbsStr[2:6])
-		if e != nil {
-			err = e
-			return
+// missingTrustees returns the fingerprints of the trustees who could provide the missing uncrumbs, in the order of their slice index,
+// the fingerprints of alternative trustees for the same slice being separated by " or "
+func missingTrustees(crumbs encrypter.Crumbs, uncrumbs map[int]decrypter.Uncrumb) (missing []string) {
+	alternatives := make(map[int][]string)
+	var indices []int
+	for _, crumb := range crumbs {
+		if _, found := uncrumbs[crumb.Index]; found || crumb.Index == 0 {
+			continue
 		}
-		nextCrumb := crumbsStr[:nextLen+6]
-		crumb, e := encrypter.ToCrumb(nextCrumb)
-		if e != nil {
-			err = e
-			return
+		if _, found := alternatives[crumb.Index]; !found {
+			indices = append(indices, crumb.Index)
 		}
-		crumbs = append(crumbs, crumb)
-		crumbsStr = crumbsStr[nextLen+6:]
+		alternatives[crumb.Index] = append(alternatives[crumb.Index], crumb.Fingerprint)
+	}
+	sort.Ints(indices)
+	for _, idx := range indices {
+		missing = append(missing, strings.Join(alternatives[idx], " or "))
 	}
 	return
 }
+
+// warning writes the passed message to the diagnostics writer, if any
+func (u *Uncrumbl) warning(args ...interface{}) {
+	if u.Diagnostics != nil {
+		fmt.Fprintln(u.Diagnostics, append([]interface{}{"WARNING -"}, args...)...)
+	}
+}
+
+// ExtractData ...
+func ExtractData(crumbled string) (verificationHash string, crumbs encrypter.Crumbs, err error) {
+	c, err := Parse(crumbled)
+	if err != nil {
+		return
+	}
+	vh, err := hasher.Unapply(c.Hashered, c.Crumbs)
+	if err != nil {
+		return
+	}
+	return vh, c.Crumbs, nil
+}
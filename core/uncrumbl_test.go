@@ -1,13 +1,19 @@
 package core_test
 
 import (
+	"bytes"
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/cyrildever/crumbl-exe/core"
 	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/crypto/shamir"
 	"github.com/cyrildever/crumbl-exe/decrypter"
+	"github.com/cyrildever/crumbl-exe/encrypter"
 	"github.com/cyrildever/crumbl-exe/models/signer"
+	"github.com/cyrildever/crumbl-exe/obfuscator"
+	"github.com/cyrildever/crumbl-exe/slicer"
 	"github.com/cyrildever/crumbl-exe/utils"
 
 	"gotest.tools/assert"
@@ -58,7 +64,7 @@ func TestUncrumbl(t *testing.T) {
 	var fromTrustees []decrypter.Uncrumb
 	for _, u := range uncrumbs {
 		parts := strings.SplitN(string(u), ".", 2)
-		if parts[1] != core.VERSION {
+		if !core.IsSupportedVersion(parts[1]) {
 			t.Fatalf("invalid version: %s\n", parts[1])
 		}
 		us := parts[0][crypto.DEFAULT_HASH_LENGTH:]
@@ -92,6 +98,567 @@ func TestUncrumbl(t *testing.T) {
 	assert.Equal(t, ref, string(uncrumbled))
 }
 
+// TestUncrumblSignedPartialUncrumbs ...
+func TestUncrumblSignedPartialUncrumbs(t *testing.T) {
+	source := "cdever@edgewhere.fr"
+	trustees := []signer.Signer{
+		{EncryptionAlgorithm: crypto.ECIES_ALGORITHM, PublicKey: trustee1_pubkey, PrivateKey: trustee1_privkey},
+		{EncryptionAlgorithm: crypto.RSA_ALGORITHM, PublicKey: trustee2_pubkey, PrivateKey: trustee2_privkey},
+	}
+	c := core.Crumbl{
+		Source:     source,
+		HashEngine: crypto.DEFAULT_HASH_ENGINE,
+		Owners:     []signer.Signer{{EncryptionAlgorithm: crypto.ECIES_ALGORITHM, PublicKey: owner1_pubkey}},
+		Trustees: []signer.Signer{
+			{EncryptionAlgorithm: crypto.ECIES_ALGORITHM, PublicKey: trustee1_pubkey},
+			{EncryptionAlgorithm: crypto.RSA_ALGORITHM, PublicKey: trustee2_pubkey},
+		},
+	}
+	crumbled, err := c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verificationHash, crumbs, err := core.ExtractData(crumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1- As trustees, the partial uncrumbs being signed as of version 7
+	var fromTrustees []decrypter.Uncrumb
+	var signed []core.PartialUncrumbs
+	for _, trustee := range trustees {
+		uTrustee := core.Uncrumbl{
+			Crumbled:         crumbled,
+			VerificationHash: verificationHash,
+			Signer:           trustee,
+		}
+		partial, err := uTrustee.Process()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Assert(t, strings.Contains(string(partial), "."+core.VERSION+".s:"))
+		partialUncrumbs, err := core.ParsePartialUncrumbs(string(partial))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NilError(t, partialUncrumbs.Verify(trustees, crumbs))
+		signed = append(signed, partialUncrumbs)
+		fromTrustees = append(fromTrustees, partialUncrumbs.Uncrumbs...)
+	}
+	forged := signed[0]
+	forged.Uncrumbs = append([]decrypter.Uncrumb{}, signed[0].Uncrumbs...)
+	forged.Uncrumbs[0].Index = 2
+	assert.Assert(t, errors.Is(forged.Verify(trustees, crumbs), crypto.ErrInvalidSignature))
+	assert.Assert(t, errors.Is(signed[0].Verify(trustees[1:], crumbs), core.ErrUnknownSigner))
+
+	// A trustee may not sign the uncrumbs of another trustee's index
+	misplaced := signed[0]
+	misplaced.Uncrumbs = append([]decrypter.Uncrumb{}, signed[0].Uncrumbs...)
+	misplaced.Uncrumbs[0].Index = signed[1].Uncrumbs[0].Index
+	if err = misplaced.Sign(trustees[0]); err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, errors.Is(misplaced.Verify(trustees, crumbs), core.ErrMisplacedUncrumb))
+
+	// 2- As an owner
+	uOwner := core.Uncrumbl{
+		Crumbled:         crumbled,
+		Slices:           fromTrustees,
+		VerificationHash: verificationHash,
+		Signer: signer.Signer{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           owner1_pubkey,
+			PrivateKey:          owner1_privkey,
+		},
+		IsOwner: true,
+	}
+	uncrumbled, err := uOwner.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(uncrumbled), source)
+}
+
+// TestUncrumblWithFingerprints ...
+func TestUncrumblWithFingerprints(t *testing.T) {
+	source := "cdever@edgewhere.fr"
+	c := core.Crumbl{
+		Source:     source,
+		HashEngine: crypto.DEFAULT_HASH_ENGINE,
+		Owners: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+				PublicKey:           owner1_pubkey,
+			},
+		},
+		Trustees: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+				PublicKey:           trustee1_pubkey,
+			},
+		},
+	}
+	crumbled, err := c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verificationHash, _, err := core.ExtractData(crumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A signer who isn't a recipient of the crumbl is told so
+	var diagnostics bytes.Buffer
+	uStranger := core.Uncrumbl{
+		Crumbled:         crumbled,
+		VerificationHash: verificationHash,
+		Signer: signer.Signer{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           trustee3_pubkey,
+			PrivateKey:          trustee3_privkey,
+		},
+		Diagnostics: &diagnostics,
+	}
+	_, err = uStranger.Process()
+	assert.Assert(t, errors.Is(err, core.ErrNotATrustee))
+
+	// The owner is told which trustee is missing
+	uOwner := core.Uncrumbl{
+		Crumbled:         crumbled,
+		VerificationHash: verificationHash,
+		Signer: signer.Signer{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           owner1_pubkey,
+			PrivateKey:          owner1_privkey,
+		},
+		IsOwner:     true,
+		Diagnostics: &diagnostics,
+	}
+	partial, err := uOwner.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerUncrumbs, err := core.ParsePartialUncrumbs(string(partial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ownerUncrumbs.Version, core.VERSION)
+	assert.Assert(t, strings.Contains(diagnostics.String(), "WARNING - missing partial uncrumb from trustee 8586d76f\n"))
+
+	uTrustee := core.Uncrumbl{
+		Crumbled:         crumbled,
+		VerificationHash: verificationHash,
+		Signer: signer.Signer{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           trustee1_pubkey,
+			PrivateKey:          trustee1_privkey,
+		},
+	}
+	partial, err = uTrustee.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbs, err := core.GetUncrumbs(string(partial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uOwner.Slices = uncrumbs
+	uncrumbled, err := uOwner.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(uncrumbled), source)
+}
+
+// TestUncrumblWithManyTrustees ...
+func TestUncrumblWithManyTrustees(t *testing.T) {
+	source := "cdever@edgewhere.fr"
+	var trustees []signer.Signer
+	for i := 0; i < 5; i++ {
+		sk, pk, err := crypto.GenerateKeyPair(crypto.ECIES_ALGORITHM, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		privkey, _ := crypto.GetKeyBytes(string(sk), crypto.ECIES_ALGORITHM)
+		pubkey, _ := crypto.GetKeyBytes(string(pk), crypto.ECIES_ALGORITHM)
+		trustees = append(trustees, signer.Signer{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           pubkey,
+			PrivateKey:          privkey,
+		})
+	}
+	c := core.Crumbl{
+		Source:     source,
+		HashEngine: crypto.DEFAULT_HASH_ENGINE,
+		Owners: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+				PublicKey:           owner1_pubkey,
+			},
+		},
+		Trustees:   trustees,
+		Redundancy: 3,
+	}
+	crumbled, err := c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	description, err := core.Inspect(crumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, description.NumberOfSlices, 6)
+	for _, slice := range description.Slices[1:] {
+		assert.Equal(t, len(slice.Crumbs), 3)
+	}
+
+	// Any three trustees hold all the slices with such redundancy
+	var uncrumbs []decrypter.Uncrumb
+	for _, trustee := range trustees[2:] {
+		uTrustee := core.Uncrumbl{
+			Crumbled: crumbled,
+			Signer:   trustee,
+		}
+		partial, err := uTrustee.Process()
+		if err != nil {
+			t.Fatal(err)
+		}
+		us, err := core.GetUncrumbs(string(partial))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(us), 3)
+		uncrumbs = append(uncrumbs, us...)
+	}
+	uOwner := core.Uncrumbl{
+		Crumbled: crumbled,
+		Slices:   uncrumbs,
+		Signer: signer.Signer{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           owner1_pubkey,
+			PrivateKey:          owner1_privkey,
+		},
+		IsOwner: true,
+	}
+	uncrumbled, err := uOwner.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(uncrumbled), source)
+
+	// Older versions are still limited to slicer.MAX_SLICES slices
+	c.Version = "2"
+	crumbled, err = c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	description, _ = core.Inspect(crumbled)
+	assert.Equal(t, description.NumberOfSlices, 1+slicer.MAX_SLICES)
+}
+
+// TestUncrumblWithThreshold ...
+func TestUncrumblWithThreshold(t *testing.T) {
+	source := "cdever@edgewhere.fr"
+	var trustees []signer.Signer
+	for i := 0; i < 5; i++ {
+		sk, pk, err := crypto.GenerateKeyPair(crypto.ECIES_ALGORITHM, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		privkey, _ := crypto.GetKeyBytes(string(sk), crypto.ECIES_ALGORITHM)
+		pubkey, _ := crypto.GetKeyBytes(string(pk), crypto.ECIES_ALGORITHM)
+		trustees = append(trustees, signer.Signer{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           pubkey,
+			PrivateKey:          privkey,
+		})
+	}
+	c := core.Crumbl{
+		Source:     source,
+		HashEngine: crypto.DEFAULT_HASH_ENGINE,
+		Owners: []signer.Signer{
+			{
+				EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+				PublicKey:           owner1_pubkey,
+			},
+		},
+		Trustees:  trustees,
+		Threshold: 3,
+	}
+	crumbled, err := c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, strings.HasSuffix(crumbled, "."+core.VERSION+".t:3"))
+
+	var partials [][]decrypter.Uncrumb
+	for _, trustee := range trustees {
+		uTrustee := core.Uncrumbl{
+			Crumbled: crumbled,
+			Signer:   trustee,
+		}
+		partial, err := uTrustee.Process()
+		if err != nil {
+			t.Fatal(err)
+		}
+		uncrumbs, err := core.GetUncrumbs(string(partial))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(uncrumbs), 1)
+		partials = append(partials, uncrumbs)
+	}
+
+	var diagnostics bytes.Buffer
+	uOwner := core.Uncrumbl{
+		Crumbled: crumbled,
+		Signer: signer.Signer{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           owner1_pubkey,
+			PrivateKey:          owner1_privkey,
+		},
+		IsOwner:     true,
+		Diagnostics: &diagnostics,
+	}
+
+	// Any three trustees are enough...
+	for _, chosen := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}} {
+		uOwner.Slices = nil
+		for _, i := range chosen {
+			uOwner.Slices = append(uOwner.Slices, partials[i]...)
+		}
+		uncrumbled, err := uOwner.Process()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, string(uncrumbled), source)
+	}
+
+	// ... but two aren't
+	uOwner.Slices = append(partials[1], partials[3]...)
+	partial, err := uOwner.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, strings.Contains(string(partial), decrypter.PARTIAL_PREFIX+"00"))
+	assert.Assert(t, strings.Contains(diagnostics.String(), "WARNING - missing 1 more partial uncrumb(s) from any of trustees "))
+
+	c.Redundancy = 2
+	_, err = c.Process()
+	assert.Error(t, err, "redundancy can't be used along with a threshold")
+	c.Redundancy = 0
+	c.Threshold = 6
+	_, err = c.Process()
+	assert.Assert(t, errors.Is(err, shamir.ErrInvalidThreshold))
+	c.Threshold = 2
+	c.Version = "3"
+	_, err = c.Process()
+	assert.Error(t, err, "threshold not supported in version 3")
+}
+
+// TestUncrumblWithHashEngine ...
+func TestUncrumblWithHashEngine(t *testing.T) {
+	source := "cdever@edgewhere.fr"
+	owner := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           owner1_pubkey,
+		PrivateKey:          owner1_privkey,
+	}
+	trustee := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           trustee1_pubkey,
+		PrivateKey:          trustee1_privkey,
+	}
+	for _, engine := range []string{crypto.SHA512_HASH_ENGINE, crypto.SHA3_HASH_ENGINE, crypto.BLAKE2B_HASH_ENGINE} {
+		c := core.Crumbl{
+			Source:     source,
+			HashEngine: engine,
+			Owners:     []signer.Signer{{EncryptionAlgorithm: owner.EncryptionAlgorithm, PublicKey: owner.PublicKey}},
+			Trustees:   []signer.Signer{{EncryptionAlgorithm: trustee.EncryptionAlgorithm, PublicKey: trustee.PublicKey}},
+		}
+		crumbled, err := c.Process()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Assert(t, strings.HasSuffix(crumbled, "."+core.VERSION+".h:"+engine))
+
+		hash, _ := crypto.Hash([]byte(source), engine)
+		verificationHash, _, err := core.ExtractData(crumbled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, verificationHash, utils.ToHex(hash))
+
+		uTrustee := core.Uncrumbl{
+			Crumbled: crumbled,
+			Signer:   trustee,
+		}
+		partial, err := uTrustee.Process()
+		if err != nil {
+			t.Fatal(err)
+		}
+		uncrumbs, err := core.GetUncrumbs(string(partial))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(uncrumbs), 1)
+
+		uOwner := core.Uncrumbl{
+			Crumbled:         crumbled,
+			Slices:           uncrumbs,
+			VerificationHash: verificationHash,
+			Signer:           owner,
+			IsOwner:          true,
+		}
+		uncrumbled, err := uOwner.Process()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, string(uncrumbled), source)
+
+		c.Version = "4"
+		_, err = c.Process()
+		assert.Error(t, err, "hash engine not supported in version 4")
+	}
+}
+
+// TestUncrumblWithObfuscationKey ...
+func TestUncrumblWithObfuscationKey(t *testing.T) {
+	source := "cdever@edgewhere.fr"
+	owner := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           owner1_pubkey,
+		PrivateKey:          owner1_privkey,
+	}
+	trustee := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           trustee1_pubkey,
+		PrivateKey:          trustee1_privkey,
+	}
+	key, err := obfuscator.ParseKey("keccak-256:12:8ed9dcc1701c064f0fd7ae235f15143f989920e0ee9658bb7882c8d7d5f05692")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := core.Crumbl{
+		Source:         source,
+		HashEngine:     crypto.DEFAULT_HASH_ENGINE,
+		Owners:         []signer.Signer{{EncryptionAlgorithm: owner.EncryptionAlgorithm, PublicKey: owner.PublicKey}},
+		Trustees:       []signer.Signer{{EncryptionAlgorithm: trustee.EncryptionAlgorithm, PublicKey: trustee.PublicKey}},
+		ObfuscationKey: key,
+	}
+	crumbled, err := c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, strings.HasSuffix(crumbled, "."+core.VERSION+".k:"+key.ID()))
+
+	uTrustee := core.Uncrumbl{
+		Crumbled: crumbled,
+		Signer:   trustee,
+	}
+	partial, err := uTrustee.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uncrumbs, err := core.GetUncrumbs(string(partial))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verificationHash, _, _ := core.ExtractData(crumbled)
+	uOwner := core.Uncrumbl{
+		Crumbled:         crumbled,
+		Slices:           uncrumbs,
+		VerificationHash: verificationHash,
+		Signer:           owner,
+		IsOwner:          true,
+	}
+	_, err = uOwner.Process()
+	assert.Assert(t, errors.Is(err, core.ErrUnknownObfuscationKey))
+
+	other, _ := obfuscator.GenerateKey()
+	uOwner.ObfuscationKeys = []obfuscator.Key{other, key}
+	uncrumbled, err := uOwner.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(uncrumbled), source)
+
+	c.Version = "7"
+	_, err = c.Process()
+	assert.Error(t, err, "obfuscation key not supported in version 7")
+}
+
+// TestUncrumblLargeSource ...
+func TestUncrumblLargeSource(t *testing.T) {
+	source := strings.Repeat("cdever@edgewhere.fr ", 8000)
+	owner := signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           owner1_pubkey,
+		PrivateKey:          owner1_privkey,
+	}
+	trustees := []signer.Signer{
+		{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           trustee1_pubkey,
+			PrivateKey:          trustee1_privkey,
+		},
+		{
+			EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+			PublicKey:           trustee3_pubkey,
+			PrivateKey:          trustee3_privkey,
+		},
+	}
+	c := core.Crumbl{
+		Source:   source,
+		Owners:   []signer.Signer{owner},
+		Trustees: trustees,
+	}
+	crumbled, err := c.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := core.Parse(crumbled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, crumb := range parsed.Crumbs {
+		assert.Assert(t, crumb.Length > encrypter.MAX_FIXED_LENGTH)
+	}
+
+	var partials []decrypter.Uncrumb
+	for _, trustee := range trustees {
+		uTrustee := core.Uncrumbl{
+			Crumbled: crumbled,
+			Signer:   trustee,
+		}
+		partial, err := uTrustee.Process()
+		if err != nil {
+			t.Fatal(err)
+		}
+		uncrumbs, err := core.GetUncrumbs(string(partial))
+		if err != nil {
+			t.Fatal(err)
+		}
+		partials = append(partials, uncrumbs...)
+	}
+	uOwner := core.Uncrumbl{
+		Crumbled: crumbled,
+		Slices:   partials,
+		Signer:   owner,
+		IsOwner:  true,
+	}
+	uncrumbled, err := uOwner.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(uncrumbled), source)
+
+	c.Version = "5"
+	_, err = c.Process()
+	assert.Assert(t, errors.Is(err, encrypter.ErrCrumbTooLong))
+}
+
 // TestExtractData ...
 func TestExtractData(t *testing.T) {
 	verificationHash := "580fb8a91f05833200dea7d33536aaec9d7ceb256a9858ee68e330e126ba409d"
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/cyrildever/crumbl-exe/encrypter"
+	"github.com/cyrildever/crumbl-exe/slicer"
+)
+
+const (
+	// VERSION is the latest version of the crumbl format, used when creating a crumbl unless told otherwise
+	VERSION = "8" // TODO Change when necessary (change of hash algorithm, modification of string structure, etc.)
+)
+
+// versions lists the supported versions of the crumbl format, each version adding its features to the previous ones:
+// - "1": the original format;
+// - "2": each crumb holds the fingerprint of the public key it was encrypted with;
+// - "3": the data is sliced for as many trustees as passed instead of slicer.MAX_SLICES at most;
+// - "4": the trustees' slice may be shared among them, any threshold of them being able to recover it;
+// - "5": the source may be hashed with another engine than the default one;
+// - "6": the length of each crumb is written with a variable width, lifting the limit of 65 535 characters per encrypted crumb;
+// - "7": the crumbl may be signed by its emitter, and the partial uncrumbs are signed by their trustee;
+// - "8": the source may be obfuscated with another key than the default one, referenced by its ID.
+var versions = map[string]features{
+	"1": {trusteeSlices: slicer.MAX_SLICES},
+	"2": {layout: encrypter.Layout{Fingerprint: true}, trusteeSlices: slicer.MAX_SLICES},
+	"3": {layout: encrypter.Layout{Fingerprint: true}, trusteeSlices: slicer.MAX_INDEXED_SLICES - 1},
+	"4": {layout: encrypter.Layout{Fingerprint: true}, trusteeSlices: slicer.MAX_INDEXED_SLICES - 1, threshold: true},
+	"5": {layout: encrypter.Layout{Fingerprint: true}, trusteeSlices: slicer.MAX_INDEXED_SLICES - 1, threshold: true, hashEngine: true},
+	"6": {layout: encrypter.Layout{Fingerprint: true, VariableLength: true}, trusteeSlices: slicer.MAX_INDEXED_SLICES - 1, threshold: true, hashEngine: true},
+	"7": {layout: encrypter.Layout{Fingerprint: true, VariableLength: true}, trusteeSlices: slicer.MAX_INDEXED_SLICES - 1, threshold: true, hashEngine: true, signature: true, partialSignature: true},
+	"8": {layout: encrypter.Layout{Fingerprint: true, VariableLength: true}, trusteeSlices: slicer.MAX_INDEXED_SLICES - 1, threshold: true, hashEngine: true, signature: true, partialSignature: true, obfuscationKey: true},
+}
+
+//--- TYPES
+
+// features holds what a version of the crumbl format supports
+type features struct {
+	layout           encrypter.Layout
+	trusteeSlices    int  // The maximum number of slices for trustees
+	threshold        bool // Whether the trustees' slice may be shared
+	hashEngine       bool // Whether the hash engine may be chosen
+	signature        bool // Whether the emitter may sign the crumbl
+	partialSignature bool // Whether the trustees sign their partial uncrumbs
+	obfuscationKey   bool // Whether the obfuscation key may be chosen
+}
+
+//--- FUNCTIONS
+
+// IsSupportedVersion returns `true` if the passed version of the crumbl format can be processed
+func IsSupportedVersion(version string) bool {
+	_, found := versions[version]
+	return found
+}
+
+// featuresOf returns the features of the passed version, or an ErrVersionMismatch if not supported
+func featuresOf(version string) (f features, err error) {
+	f, found := versions[version]
+	if !found {
+		err = fmt.Errorf("%w: %s", ErrVersionMismatch, version)
+	}
+	return
+}
@@ -2,10 +2,19 @@ package crypto
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"hash"
 	"strings"
+	"sync"
 
+	"github.com/cyrildever/crumbl-exe/crypto/ecies"
+	"github.com/cyrildever/crumbl-exe/crypto/rsa"
 	"github.com/cyrildever/crumbl-exe/utils"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
 )
 
 const (
@@ -15,18 +24,54 @@ const (
 	// DEFAULT_HASH_LENGTH ...
 	DEFAULT_HASH_LENGTH = 64
 
+	// SHA512_HASH_ENGINE ...
+	SHA512_HASH_ENGINE = "sha-512"
+
+	// SHA3_HASH_ENGINE ...
+	SHA3_HASH_ENGINE = "sha3-256"
+
+	// BLAKE2B_HASH_ENGINE ...
+	BLAKE2B_HASH_ENGINE = "blake2b-512"
+
 	// ECIES_ALGORITHM ...
 	ECIES_ALGORITHM = "ecies"
 
 	// RSA_ALGORITHM ...
 	RSA_ALGORITHM = "rsa"
+
+	// DEFAULT_RSA_BITS ...
+	DEFAULT_RSA_BITS = 2048
+
+	// MIN_RSA_BITS is the minimum size of a RSA key able to hold a slice with OAEP padding using SHA-512
+	MIN_RSA_BITS = 2048
+
+	// FINGERPRINT_LENGTH is the number of hexadecimal characters of a public key fingerprint
+	FINGERPRINT_LENGTH = 8
+)
+
+var (
+	hashEngines = map[string]func() hash.Hash{
+		DEFAULT_HASH_ENGINE: sha256.New,
+		SHA512_HASH_ENGINE:  sha512.New,
+		SHA3_HASH_ENGINE:    sha3.New256,
+		BLAKE2B_HASH_ENGINE: func() hash.Hash {
+			h, _ := blake2b.New512(nil) // Never fails without a key
+			return h
+		},
+	}
+	hashEnginesMutex sync.RWMutex
 )
 
 const authorizedAlgorithms = ECIES_ALGORITHM + ":" + RSA_ALGORITHM // TODO Add any new authorized algorithm name after a colon
 
 // ExistsAlgorithm ...
 func ExistsAlgorithm(name string) bool {
-	return strings.Contains(authorizedAlgorithms, name)
+	for _, algo := range strings.Split(authorizedAlgorithms, ":") {
+		if algo == name {
+			return true
+		}
+	}
+	return false
 }
 
 // GetKeyBytes returns the appropriate byte array for the passed key and algorithm name
@@ -41,19 +86,177 @@ func GetKeyBytes(key string, algo string) (bytes []byte, err error) {
 	}
 }
 
-// Hash hashes the passed byte array using SHA-256 hash algorithm (as of the latest version of the Crumbl&trade;)
-func Hash(input []byte, engine string) (h []byte, err error) {
-	if engine == DEFAULT_HASH_ENGINE {
-		hasher := sha256.New()
-		_, e := hasher.Write(input)
-		if err != nil {
+// GenerateKeyPair returns a new key pair for the passed algorithm in the format of the key files read by GetKeyBytes, ie.
+// the hexadecimal representation of the private key and the uncompressed public key for ECIES, and PEM blocks for RSA.
+// The bits parameter is only used for RSA keys, falling back to DEFAULT_RSA_BITS if not strictly positive.
+func GenerateKeyPair(algo string, bits int) (privateKey, publicKey []byte, err error) {
+	switch algo {
+	case ECIES_ALGORITHM:
+		sk, pk, e := ecies.GenerateKeyPair()
+		if e != nil {
+			err = e
+			return
+		}
+		privateKey = []byte(utils.ToHex(sk))
+		publicKey = []byte(utils.ToHex(pk))
+	case RSA_ALGORITHM:
+		if bits <= 0 {
+			bits = DEFAULT_RSA_BITS
+		}
+		if bits < MIN_RSA_BITS {
+			err = fmt.Errorf("RSA keys must be at least %d bits long", MIN_RSA_BITS)
+			return
+		}
+		sk, pk, e := rsa.GenerateKeyPair(bits)
+		if e != nil {
 			err = e
 			return
 		}
-		h = hasher.Sum(nil)
-	} else {
-		err = errors.New("invalid hash engine")
+		privateKey = rsa.PrivateKeyToBytes(sk)
+		publicKey, err = rsa.PublicKeyToBytes(pk)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algo)
+	}
+	return
+}
+
+// Fingerprint returns a short identifier of the passed public key, ie. the first bytes of the SHA-256 hash of its canonical form
+// (the uncompressed point for ECIES and the DER-encoded PKIX structure for RSA), as a hexadecimal string of FINGERPRINT_LENGTH characters.
+func Fingerprint(publicKey []byte, algo string) (fingerprint string, err error) {
+	var canonical []byte
+	switch algo {
+	case ECIES_ALGORITHM:
+		if _, e := ecies.PublicKeyFrom(publicKey); e != nil {
+			err = e
+			return
+		}
+		canonical = publicKey
+	case RSA_ALGORITHM:
+		pk, e := rsa.BytesToPublicKey(publicKey)
+		if e != nil {
+			err = e
+			return
+		}
+		canonical, err = x509.MarshalPKIXPublicKey(pk)
+		if err != nil {
+			return
+		}
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algo)
 		return
 	}
+	hash := sha256.Sum256(canonical)
+	fingerprint = utils.ToHex(hash[:FINGERPRINT_LENGTH/2])
 	return
 }
+
+// Sign returns the signature of the passed message with the private key of the passed encryption algorithm, ie.
+// an ECDSA signature over secp256k1 for ECIES keys and a RSA-PSS signature for RSA keys, both of the SHA-256 hash of the message.
+func Sign(message, privateKey []byte, algo string) ([]byte, error) {
+	switch algo {
+	case ECIES_ALGORITHM:
+		return ecies.Sign(message, privateKey)
+	case RSA_ALGORITHM:
+		return rsa.Sign(message, privateKey)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algo)
+	}
+}
+
+// Verify checks that the passed signature of the message was made with the private key of the passed public key (see Sign)
+func Verify(message, signature, publicKey []byte, algo string) error {
+	valid := false
+	switch algo {
+	case ECIES_ALGORITHM:
+		valid = ecies.Verify(message, signature, publicKey)
+	case RSA_ALGORITHM:
+		valid = rsa.Verify(message, signature, publicKey)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algo)
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// GuessAlgorithm returns the encryption algorithm that most likely produced the passed ciphertext, or an empty string if unknown.
+// An ECIES ciphertext starts with the ephemeral public key which must be a valid point of the curve,
+// whereas a RSA ciphertext is exactly as long as the modulus of the key, ie. at least MIN_RSA_BITS.
+func GuessAlgorithm(ciphertext []byte) string {
+	if len(ciphertext) > ecies.OVERHEAD {
+		if _, err := ecies.PublicKeyFrom(ciphertext[:ecies.PUBLIC_KEY_LENGTH]); err == nil {
+			return ECIES_ALGORITHM
+		}
+	}
+	if len(ciphertext) >= MIN_RSA_BITS/8 && len(ciphertext)%8 == 0 {
+		return RSA_ALGORITHM
+	}
+	return ""
+}
+
+// RegisterHashEngine makes the passed hash function available under the passed name, eg. to use it in a crumbl.
+// The name can't hold any dot nor colon as it may be written in the crumbl, and an already registered engine can't be replaced.
+func RegisterHashEngine(name string, newHash func() hash.Hash) error {
+	if name == "" || strings.ContainsAny(name, ".:") || newHash == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownHashEngine, name)
+	}
+	hashEnginesMutex.Lock()
+	defer hashEnginesMutex.Unlock()
+	if _, found := hashEngines[name]; found {
+		return fmt.Errorf("hash engine already registered: %s", name)
+	}
+	hashEngines[name] = newHash
+	return nil
+}
+
+// ExistsHashEngine ...
+func ExistsHashEngine(name string) bool {
+	_, found := getHashEngine(name)
+	return found
+}
+
+// HashLength returns the length of the hexadecimal representation of a hash computed with the passed engine
+func HashLength(engine string) (int, error) {
+	newHash, found := getHashEngine(engine)
+	if !found {
+		return 0, ErrUnknownHashEngine
+	}
+	return 2 * newHash().Size(), nil
+}
+
+// Hash hashes the passed byte array using the passed hash engine, ie. the DEFAULT_HASH_ENGINE, SHA-512, SHA3-256, BLAKE2b-512
+// or any registered one
+func Hash(input []byte, engine string) (h []byte, err error) {
+	newHash, found := getHashEngine(engine)
+	if !found {
+		err = ErrUnknownHashEngine
+		return
+	}
+	hasher := newHash()
+	if _, err = hasher.Write(input); err != nil {
+		return
+	}
+	h = hasher.Sum(nil)
+	return
+}
+
+func getHashEngine(name string) (newHash func() hash.Hash, found bool) {
+	hashEnginesMutex.RLock()
+	defer hashEnginesMutex.RUnlock()
+	newHash, found = hashEngines[name]
+	return
+}
+
+//--- ERRORS
+
+var (
+	// ErrUnknownAlgorithm is returned when the encryption algorithm is not one of the authorized algorithms
+	ErrUnknownAlgorithm = errors.New("unknown encryption algorithm")
+
+	// ErrUnknownHashEngine is returned when the hash engine is not supported
+	ErrUnknownHashEngine = errors.New("invalid hash engine")
+
+	// ErrInvalidSignature is returned when a signature doesn't match the message and the public key
+	ErrInvalidSignature = errors.New("invalid signature")
+)
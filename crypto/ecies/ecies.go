@@ -4,13 +4,23 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"errors"
 	"math/big"
 
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	ethecies "github.com/ethereum/go-ethereum/crypto/ecies"
 	"github.com/ethereum/go-ethereum/crypto/secp256k1"
 )
 
+const (
+	// PUBLIC_KEY_LENGTH is the length of an uncompressed public key, ie. 0x04 followed by both 32-byte coordinates
+	PUBLIC_KEY_LENGTH = 65
+
+	// OVERHEAD is the number of bytes added to the message by the encryption, ie. the ephemeral public key, the IV and the MAC
+	OVERHEAD = PUBLIC_KEY_LENGTH + 16 + 32
+)
+
 // Encrypt ...
 func Encrypt(msg, publicKeyBytes []byte) ([]byte, error) {
 	pk, err := PublicKeyFrom(publicKeyBytes)
@@ -29,6 +39,36 @@ func Decrypt(ciphered, privateKeyBytes, publicKeyBytes []byte) ([]byte, error) {
 	return sk.Decrypt(ciphered, nil, nil)
 }
 
+// Sign returns the ECDSA signature of the SHA-256 hash of the message with the passed secp256k1 private key, in the 65-byte [R || S || V] format
+func Sign(msg, privateKeyBytes []byte) ([]byte, error) {
+	sk, err := ethcrypto.ToECDSA(privateKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(msg)
+	return ethcrypto.Sign(hash[:], sk)
+}
+
+// Verify tells whether the passed signature of the message was made with the private key of the passed public key
+func Verify(msg, signature, publicKeyBytes []byte) bool {
+	if len(signature) != 65 {
+		return false
+	}
+	hash := sha256.Sum256(msg)
+	return ethcrypto.VerifySignature(publicKeyBytes, hash[:], signature[:64])
+}
+
+// GenerateKeyPair generates a new secp256k1 key pair, returning the private key bytes and the uncompressed public key bytes
+func GenerateKeyPair() (privateKeyBytes, publicKeyBytes []byte, err error) {
+	sk, err := ethcrypto.GenerateKey()
+	if err != nil {
+		return
+	}
+	privateKeyBytes = ethcrypto.FromECDSA(sk)
+	publicKeyBytes = ethcrypto.FromECDSAPub(&sk.PublicKey)
+	return
+}
+
 // PublicKeyFrom ...
 func PublicKeyFrom(publicKeyBytes []byte) (pk ethecies.PublicKey, err error) {
 	x, y := elliptic.Unmarshal(secp256k1.S256(), publicKeyBytes)
@@ -1,11 +1,14 @@
 package rsa
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/sha512"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 )
 
@@ -27,6 +30,26 @@ func Decrypt(ciphered, privateKeyBytes []byte) ([]byte, error) {
 	return DecryptWithPrivateKey(ciphered, sk)
 }
 
+// Sign returns the RSA-PSS signature of the SHA-256 hash of the message with the passed private key
+func Sign(msg, privateKeyBytes []byte) ([]byte, error) {
+	sk, err := BytesToPrivateKey(privateKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(msg)
+	return rsa.SignPSS(rand.Reader, sk, crypto.SHA256, hash[:], nil)
+}
+
+// Verify tells whether the passed signature of the message was made with the private key of the passed public key
+func Verify(msg, signature, publicKeyBytes []byte) bool {
+	pk, err := BytesToPublicKey(publicKeyBytes)
+	if err != nil {
+		return false
+	}
+	hash := sha256.Sum256(msg)
+	return rsa.VerifyPSS(pk, crypto.SHA256, hash[:], signature, nil) == nil
+}
+
 //--- utilities
 
 // GenerateKeyPair generates a new key pair
@@ -85,6 +108,10 @@ func BytesToPrivateKey(priv []byte) (sk *rsa.PrivateKey, err error) {
 // BytesToPublicKey converts bytes to public key
 func BytesToPublicKey(pub []byte) (pk *rsa.PublicKey, err error) {
 	block, _ := pem.Decode(pub)
+	if block == nil {
+		err = errors.New("invalid public key: not a PEM block")
+		return
+	}
 	enc := x509.IsEncryptedPEMBlock(block)
 	b := block.Bytes
 	if enc {
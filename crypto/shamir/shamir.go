@@ -0,0 +1,130 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Shamir's secret sharing over GF(2^8), each byte of the secret being the constant term of its own random polynomial of degree threshold-1.
+// A share is the evaluation of these polynomials at a non-zero x coordinate: any threshold shares rebuild the secret by Lagrange
+// interpolation at zero, fewer shares revealing nothing about it.
+
+const (
+	// MAX_SHARES is the maximum number of shares as x coordinates are non-zero bytes
+	MAX_SHARES = 255
+)
+
+var (
+	expTable [255]byte
+	logTable [256]byte
+)
+
+func init() {
+	// 3 is a generator of the multiplicative group of GF(2^8) with the AES polynomial x^8 + x^4 + x^3 + x + 1
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		logTable[x] = byte(i)
+		x ^= mul2(x)
+	}
+}
+
+//--- FUNCTIONS
+
+// Split returns the n shares of the passed secret, any threshold of them being needed to rebuild it.
+// The share at position i in the returned slice has the x coordinate i+1.
+func Split(secret []byte, n, threshold int) (shares [][]byte, err error) {
+	if len(secret) == 0 {
+		err = errors.New("invalid empty secret")
+		return
+	}
+	if n < 1 || n > MAX_SHARES || threshold < 1 || threshold > n {
+		err = fmt.Errorf("%w: %d of %d", ErrInvalidThreshold, threshold, n)
+		return
+	}
+	shares = make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret))
+	}
+	coefficients := make([]byte, threshold)
+	for b, value := range secret {
+		coefficients[0] = value
+		if _, err = rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			shares[i][b] = evaluate(coefficients, byte(i+1))
+		}
+	}
+	return
+}
+
+// Combine rebuilds the secret from the passed shares indexed by their x coordinate.
+// It can't tell if there are fewer shares than the threshold: the result would then just be wrong.
+func Combine(shares map[int][]byte) (secret []byte, err error) {
+	if len(shares) == 0 {
+		err = errors.New("no share to combine")
+		return
+	}
+	length := -1
+	for x, share := range shares {
+		if x < 1 || x > MAX_SHARES {
+			err = fmt.Errorf("invalid share coordinate: %d", x)
+			return
+		}
+		if length != -1 && len(share) != length {
+			err = errors.New("shares of different lengths")
+			return
+		}
+		length = len(share)
+	}
+	secret = make([]byte, length)
+	for xi, share := range shares {
+		// Lagrange basis polynomial for xi evaluated at zero, ie. the product of xj / (xj - xi) for all other xj (subtraction being XOR)
+		basis := byte(1)
+		for xj := range shares {
+			if xj != xi {
+				basis = mul(basis, div(byte(xj), byte(xj)^byte(xi)))
+			}
+		}
+		for b := range secret {
+			secret[b] ^= mul(share[b], basis)
+		}
+	}
+	return
+}
+
+// evaluate returns the value of the polynomial with the passed coefficients at x using Horner's method
+func evaluate(coefficients []byte, x byte) (y byte) {
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coefficients[i]
+	}
+	return
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])+int(logTable[b]))%255]
+}
+
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])-int(logTable[b])+255)%255]
+}
+
+func mul2(x byte) byte {
+	if x&0x80 != 0 {
+		return x<<1 ^ 0x1b
+	}
+	return x << 1
+}
+
+//--- ERRORS
+
+// ErrInvalidThreshold is returned when the threshold is not between 1 and the number of shares, or there are too many shares
+var ErrInvalidThreshold = errors.New("invalid threshold")
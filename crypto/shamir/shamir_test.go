@@ -0,0 +1,48 @@
+package shamir_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/crypto/shamir"
+
+	"gotest.tools/assert"
+)
+
+// TestSplitCombine ...
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("Edgewhere")
+	shares, err := shamir.Split(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(shares), 5)
+	for _, share := range shares {
+		assert.Equal(t, len(share), len(secret))
+	}
+
+	for _, xs := range [][]int{{1, 2, 3}, {1, 3, 5}, {2, 4, 5}, {1, 2, 3, 4, 5}} {
+		subset := make(map[int][]byte)
+		for _, x := range xs {
+			subset[x] = shares[x-1]
+		}
+		combined, err := shamir.Combine(subset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, string(combined), string(secret))
+	}
+
+	combined, _ := shamir.Combine(map[int][]byte{1: shares[0], 2: shares[1]})
+	assert.Assert(t, string(combined) != string(secret))
+
+	shares, _ = shamir.Split(secret, 1, 1)
+	assert.DeepEqual(t, shares[0], secret)
+
+	_, err = shamir.Split(secret, 3, 4)
+	assert.Assert(t, errors.Is(err, shamir.ErrInvalidThreshold))
+	_, err = shamir.Split(secret, 256, 2)
+	assert.Error(t, err, "invalid threshold: 2 of 256")
+	_, err = shamir.Combine(map[int][]byte{0: secret})
+	assert.Error(t, err, "invalid share coordinate: 0")
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 
 	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/crypto/shamir"
 	"github.com/cyrildever/crumbl-exe/padder"
+	"github.com/cyrildever/crumbl-exe/slicer"
 	"github.com/cyrildever/crumbl-exe/utils"
 )
 
@@ -16,6 +18,7 @@ type Collector struct {
 	NumberOfSlices   int
 	VerificationHash string
 	HashEngine       string
+	Threshold        int // Optional: the number of shares needed to rebuild the trustees' slice, the uncrumbs at index 1 and above being shares
 }
 
 //--- METHODS
@@ -31,14 +34,13 @@ func (c *Collector) Check(data []byte) bool {
 
 // ToObfuscated returns the concatenated slices into the obfuscated string
 func (c *Collector) ToObfuscated() (obfuscated []byte, err error) {
+	slices, err := c.slices()
+	if err != nil {
+		return
+	}
 	var o string
-	for i := 0; i < c.NumberOfSlices; i++ {
-		uncrumb, found := c.Map[i]
-		if !found {
-			err = fmt.Errorf("missing slice with index: %d", i)
-			return
-		}
-		unpadded, _, e := padder.Unapply([]byte(uncrumb.ToSlice()))
+	for _, slice := range slices {
+		unpadded, _, e := padder.Unapply([]byte(slice))
 		if e != nil {
 			err = e
 			return
@@ -52,3 +54,60 @@ func (c *Collector) ToObfuscated() (obfuscated []byte, err error) {
 	obfuscated = oBytes
 	return
 }
+
+// slices returns the ordered slices of the obfuscated data, rebuilding the trustees' slice from its shares if need be
+func (c *Collector) slices() (slices []slicer.Slice, err error) {
+	if c.Threshold == 0 {
+		for i := 0; i < c.NumberOfSlices; i++ {
+			uncrumb, found := c.Map[i]
+			if !found {
+				err = ErrMissingSlice{Index: i}
+				return
+			}
+			slices = append(slices, uncrumb.ToSlice())
+		}
+		return
+	}
+	owners, found := c.Map[0]
+	if !found {
+		err = ErrMissingSlice{Index: 0}
+		return
+	}
+	shares := make(map[int][]byte)
+	for idx, uncrumb := range c.Map {
+		if idx > 0 && len(shares) < c.Threshold {
+			shares[idx] = uncrumb.Deciphered.Decoded()
+		}
+	}
+	if len(shares) < c.Threshold {
+		err = ErrMissingShares{Found: len(shares), Threshold: c.Threshold}
+		return
+	}
+	shared, err := shamir.Combine(shares)
+	if err != nil {
+		return
+	}
+	slices = []slicer.Slice{owners.ToSlice(), slicer.Slice(shared)}
+	return
+}
+
+//--- ERRORS
+
+// ErrMissingSlice is returned when the slice at Index is missing to rebuild the obfuscated data
+type ErrMissingSlice struct {
+	Index int
+}
+
+func (e ErrMissingSlice) Error() string {
+	return fmt.Sprintf("missing slice with index: %d", e.Index)
+}
+
+// ErrMissingShares is returned when fewer shares than the threshold were found to rebuild the trustees' slice
+type ErrMissingShares struct {
+	Found     int
+	Threshold int
+}
+
+func (e ErrMissingShares) Error() string {
+	return fmt.Sprintf("missing shares: %d found for a threshold of %d", e.Found, e.Threshold)
+}
@@ -0,0 +1,63 @@
+package decrypter_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/crypto/shamir"
+	"github.com/cyrildever/crumbl-exe/decrypter"
+	"github.com/cyrildever/crumbl-exe/models/core"
+
+	"gotest.tools/assert"
+)
+
+// TestToObfuscated ...
+func TestToObfuscated(t *testing.T) {
+	collector := decrypter.Collector{
+		Map: map[int]decrypter.Uncrumb{
+			0: {Index: 0, Deciphered: core.ToBase64([]byte("\x02\x02Cr"))},
+			2: {Index: 2, Deciphered: core.ToBase64([]byte("\x02\x02bl"))},
+		},
+		NumberOfSlices: 3,
+		HashEngine:     crypto.DEFAULT_HASH_ENGINE,
+	}
+	_, err := collector.ToObfuscated()
+	assert.Assert(t, errors.Is(err, decrypter.ErrMissingSlice{Index: 1}))
+	var missing decrypter.ErrMissingSlice
+	assert.Assert(t, errors.As(err, &missing))
+	assert.Equal(t, missing.Index, 1)
+
+	collector.Map[1] = decrypter.Uncrumb{Index: 1, Deciphered: core.ToBase64([]byte("\x02\x02um"))}
+	obfuscated, err := collector.ToObfuscated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(obfuscated), "Crumbl")
+}
+
+// TestToObfuscatedWithThreshold ...
+func TestToObfuscatedWithThreshold(t *testing.T) {
+	shares, err := shamir.Split([]byte("\x02\x02umbl"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := decrypter.Collector{
+		Map: map[int]decrypter.Uncrumb{
+			0: {Index: 0, Deciphered: core.ToBase64([]byte("\x02\x02Cr"))},
+			3: {Index: 3, Deciphered: core.ToBase64(shares[2])},
+		},
+		NumberOfSlices: 4,
+		HashEngine:     crypto.DEFAULT_HASH_ENGINE,
+		Threshold:      2,
+	}
+	_, err = collector.ToObfuscated()
+	assert.Error(t, err, "missing shares: 1 found for a threshold of 2")
+
+	collector.Map[1] = decrypter.Uncrumb{Index: 1, Deciphered: core.ToBase64(shares[0])}
+	obfuscated, err := collector.ToObfuscated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(obfuscated), "Crumbl")
+}
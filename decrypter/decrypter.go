@@ -1,7 +1,7 @@
 package decrypter
 
 import (
-	"errors"
+	"fmt"
 
 	"github.com/cyrildever/crumbl-exe/crypto"
 	"github.com/cyrildever/crumbl-exe/crypto/ecies"
@@ -30,7 +30,7 @@ func Decrypt(encrypted encrypter.Crumb, s signer.Signer) (data Uncrumb, err erro
 		}
 		dec = deciphered
 	default:
-		err = errors.New("unknown encryption algorithm: " + s.EncryptionAlgorithm)
+		err = fmt.Errorf("%w: %s", crypto.ErrUnknownAlgorithm, s.EncryptionAlgorithm)
 		return
 	}
 	data = Uncrumb{
@@ -19,8 +19,8 @@ const (
 
 // Uncrumb holds the deciphered slice and its index.
 type Uncrumb struct {
-	Deciphered core.Base64
-	Index      int
+	Deciphered core.Base64 `json:"deciphered"`
+	Index      int         `json:"index"`
 }
 
 //--- METHODS
@@ -1,36 +1,114 @@
 package encrypter
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 
+	"github.com/cyrildever/crumbl-exe/crypto"
 	"github.com/cyrildever/crumbl-exe/models/core"
 	"github.com/cyrildever/crumbl-exe/utils"
 )
 
+const (
+	// MAX_FIXED_LENGTH is the maximum length of an encrypted crumb when its length is written in four hexadecimal characters
+	MAX_FIXED_LENGTH = 0xffff
+
+	// MAX_LENGTH_WIDTH is the maximum number of hexadecimal characters of the length of an encrypted crumb when it's prefixed by its width
+	MAX_LENGTH_WIDTH = 8
+)
+
 //--- TYPES
 
-// Crumb holds the encrypted slice, its index and length.
+// Crumb holds the encrypted slice, its index and length, as well as the fingerprint of the public key it was encrypted with.
 type Crumb struct {
-	Encrypted core.Base64
-	Index     int
-	Length    int
+	Encrypted   core.Base64
+	Index       int
+	Length      int
+	Fingerprint string
 }
 
 // Crumbs ...
 type Crumbs []Crumb
 
+// crumbJSON is the JSON representation of a crumb
+type crumbJSON struct {
+	Index       int    `json:"index"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Length      int    `json:"length"`
+	Ciphertext  string `json:"ciphertext"`
+	Algorithm   string `json:"algorithm,omitempty"`
+}
+
+// Layout describes the stringified representation of a crumb, which depends on the version of the crumbl it belongs to.
+// The zero value is the original layout.
+type Layout struct {
+	Fingerprint    bool // If set, the fingerprint of the recipient's public key follows the index
+	VariableLength bool // If set, the length is written in as many hexadecimal characters as needed, prefixed by their number in one hexadecimal character
+}
+
 //--- METHODS
 
-// String transforms the Crumb into its stringified representation.
+// String transforms the Crumb into its stringified representation using the original layout.
 // The construct is as follows:
 // - the first two characters are the hexadecimal representation of the index;
 // - the following four characters are the hexadecimal representation of the length of the encrypted data to follow;
 // - the base64-encoded string.
 // NB: the condition to only use four characters for the length of the encrypted data implies that
-// this encrypted crumb shouldn't be longer than 65 535 characters, ie. 64 ko.
+// this encrypted crumb shouldn't be longer than 65 535 characters, ie. 64 ko (see Layout.Fits).
 func (c *Crumb) String() string {
-	return fmt.Sprintf("%02x", c.Index) + fmt.Sprintf("%04x", c.Length) + c.Encrypted.String()
+	return c.Stringify(Layout{})
+}
+
+// Stringify transforms the Crumb into its stringified representation using the passed layout,
+// ie. inserting the fingerprint between the index and the length if need be, and writing the length with a variable width if need be,
+// eg. `3158` for a length of 344 characters.
+// The caller should make sure beforehand that the length fits the layout.
+func (c *Crumb) Stringify(layout Layout) string {
+	str := fmt.Sprintf("%02x", c.Index)
+	if layout.Fingerprint {
+		str += c.Fingerprint
+	}
+	if layout.VariableLength {
+		hexLength := fmt.Sprintf("%x", c.Length)
+		str += fmt.Sprintf("%x", len(hexLength)) + hexLength
+	} else {
+		str += fmt.Sprintf("%04x", c.Length)
+	}
+	return str + c.Encrypted.String()
+}
+
+// MarshalJSON returns the JSON representation of the crumb, along with the encryption algorithm that was most likely used
+func (c Crumb) MarshalJSON() ([]byte, error) {
+	return json.Marshal(crumbJSON{
+		Index:       c.Index,
+		Fingerprint: c.Fingerprint,
+		Length:      c.Length,
+		Ciphertext:  c.Encrypted.String(),
+		Algorithm:   crypto.GuessAlgorithm(c.Encrypted.Bytes()),
+	})
+}
+
+// UnmarshalJSON fills the crumb from its JSON representation, the algorithm being ignored
+func (c *Crumb) UnmarshalJSON(data []byte) error {
+	var j crumbJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Ciphertext == "" || !core.IsBase64String(j.Ciphertext) {
+		return errors.New("invalid crumb: not a base64-encoded ciphertext")
+	}
+	if j.Length != len(j.Ciphertext) {
+		return errors.New("invalid crumb: incompatible lengths")
+	}
+	*c = Crumb{
+		Encrypted:   core.Base64(j.Ciphertext),
+		Index:       j.Index,
+		Length:      j.Length,
+		Fingerprint: j.Fingerprint,
+	}
+	return nil
 }
 
 // GetAt returns the crumbs having the passed index
@@ -43,49 +121,176 @@ func (cc Crumbs) GetAt(index int) (crumbs []Crumb) {
 	return
 }
 
-//--- FUNCTIONS
+// Fits returns `true` if an encrypted crumb of the passed length can be stringified using the layout
+func (l Layout) Fits(length int) bool {
+	if l.VariableLength {
+		return length <= math.MaxInt32
+	}
+	return length <= MAX_FIXED_LENGTH
+}
 
-// ToCrumb ...
-func ToCrumb(unparsed string) (c Crumb, err error) {
-	idx, length, enc, err := Parse(unparsed)
-	if err != nil {
+// minHeaderLength returns the minimum number of characters before the encrypted data
+func (l Layout) minHeaderLength() int {
+	if l.VariableLength {
+		return l.lengthPosition() + 2
+	}
+	return l.lengthPosition() + 4
+}
+
+// lengthPosition returns the position of the length (or of its width) in a stringified crumb
+func (l Layout) lengthPosition() int {
+	if l.Fingerprint {
+		return 2 + crypto.FINGERPRINT_LENGTH
+	}
+	return 2
+}
+
+// readLength returns the length of the encrypted data read in the passed stringified crumb and the number of characters before this data
+func (l Layout) readLength(unparsed string) (length int, header int, err error) {
+	start := l.lengthPosition()
+	width := 4
+	if l.VariableLength {
+		if len(unparsed) <= start {
+			err = ErrMalformedCrumb{Offset: start, Reason: "unparsed string too short"}
+			return
+		}
+		w, e := utils.HexToInt(unparsed[start : start+1])
+		if e != nil || w == 0 || w > MAX_LENGTH_WIDTH {
+			err = ErrMalformedCrumb{Offset: start, Reason: "invalid length width", Err: e}
+			return
+		}
+		width = w
+		start++
+	}
+	header = start + width
+	if len(unparsed) < header {
+		err = ErrMalformedCrumb{Offset: start, Reason: "unparsed string too short"}
 		return
 	}
-	c = Crumb{
-		Encrypted: core.Base64(enc),
-		Index:     idx,
-		Length:    length,
+	length, e := utils.HexToInt(unparsed[start:header])
+	if e != nil {
+		err = ErrMalformedCrumb{Offset: start, Reason: "invalid length", Err: e}
 	}
 	return
 }
 
-// Parse extracts the index, the encrypted length and text from the passed string, or returns an error
-func Parse(unparsed string) (index int, length int, enc string, err error) {
-	if len(unparsed) < 7 {
-		err = errors.New("unparsed string too short")
+//--- FUNCTIONS
+
+// ToCrumb ...
+func ToCrumb(unparsed string) (c Crumb, err error) {
+	return ToCrumbWith(unparsed, Layout{})
+}
+
+// ToCrumbWith parses the passed stringified crumb using the passed layout
+func ToCrumbWith(unparsed string, layout Layout) (c Crumb, err error) {
+	if len(unparsed) < layout.minHeaderLength()+1 {
+		err = ErrMalformedCrumb{Offset: 0, Reason: "unparsed string too short"}
 		return
 	}
 	idxHex := unparsed[:2]
-	idx, err := utils.HexToInt(idxHex)
-	if err != nil {
+	idx, e := utils.HexToInt(idxHex)
+	if e != nil {
+		err = ErrMalformedCrumb{Offset: 0, Reason: "invalid index", Err: e}
 		return
 	}
-	lnHex := unparsed[2:6]
-	ln, err := utils.HexToInt(lnHex)
+	var fingerprint string
+	if layout.Fingerprint {
+		fingerprint = unparsed[2 : 2+crypto.FINGERPRINT_LENGTH]
+		if _, e := utils.FromHex(fingerprint); e != nil {
+			err = ErrMalformedCrumb{Offset: 2, Reason: "invalid fingerprint", Err: e}
+			return
+		}
+	}
+	ln, header, err := layout.readLength(unparsed)
 	if err != nil {
 		return
 	}
-	e := unparsed[6:]
-	if !core.IsBase64String(e) {
-		err = errors.New("not a base64-encoded string")
+	enc := unparsed[header:]
+	if !core.IsBase64String(enc) {
+		err = ErrMalformedCrumb{Offset: header, Reason: "not a base64-encoded string"}
 		return
 	}
-	if ln != len(e) {
-		err = errors.New("incompatible lengths")
+	if ln != len(enc) {
+		err = ErrMalformedCrumb{Offset: header, Reason: "incompatible lengths"}
+		return
+	}
+	c = Crumb{
+		Encrypted:   core.Base64(enc),
+		Index:       idx,
+		Length:      ln,
+		Fingerprint: fingerprint,
+	}
+	return
+}
+
+// Parse extracts the index, the encrypted length and text from the passed string in the original layout, or returns an error
+func Parse(unparsed string) (index int, length int, encrypted string, err error) {
+	c, err := ToCrumbWith(unparsed, Layout{})
+	if err != nil {
 		return
 	}
-	index = idx
-	length = ln
-	enc = e
+	index = c.Index
+	length = c.Length
+	encrypted = c.Encrypted.String()
 	return
 }
+
+// ParseCrumbs returns the crumbs of the passed concatenation of stringified crumbs using the passed layout.
+// The offset of any returned ErrMalformedCrumb is relative to the passed string.
+func ParseCrumbs(concatenated string, layout Layout) (crumbs Crumbs, err error) {
+	offset := 0
+	for len(concatenated) > layout.minHeaderLength()+1 {
+		nextLen, header, e := layout.readLength(concatenated)
+		if e != nil {
+			malformed := e.(ErrMalformedCrumb)
+			malformed.Offset += offset
+			err = malformed
+			return
+		}
+		if nextLen+header > len(concatenated) {
+			err = ErrMalformedCrumb{Offset: offset + layout.lengthPosition(), Reason: "length out of bounds"}
+			return
+		}
+		crumb, e := ToCrumbWith(concatenated[:nextLen+header], layout)
+		if e != nil {
+			if malformed, ok := e.(ErrMalformedCrumb); ok {
+				malformed.Offset += offset
+				e = malformed
+			}
+			err = e
+			return
+		}
+		crumbs = append(crumbs, crumb)
+		concatenated = concatenated[nextLen+header:]
+		offset += nextLen + header
+	}
+	if len(concatenated) > 0 {
+		err = ErrMalformedCrumb{Offset: offset, Reason: "trailing characters"}
+	}
+	return
+}
+
+//--- ERRORS
+
+// ErrCrumbTooLong is returned when the length of an encrypted crumb can't be written in the layout of the crumbl version
+var ErrCrumbTooLong = errors.New("encrypted crumb too long")
+
+// ErrMalformedCrumb is returned when a stringified crumb can't be parsed, Offset being the position of the faulty part in the parsed string
+type ErrMalformedCrumb struct {
+	Offset int
+	Reason string
+	Err    error
+}
+
+func (e ErrMalformedCrumb) Error() string {
+	msg := fmt.Sprintf("malformed crumb at offset %d: %s", e.Offset, e.Reason)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error, if any
+func (e ErrMalformedCrumb) Unwrap() error {
+	return e.Err
+}
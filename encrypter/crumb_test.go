@@ -1,6 +1,8 @@
 package encrypter_test
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/cyrildever/crumbl-exe/encrypter"
@@ -35,4 +37,99 @@ func TestToCrumb(t *testing.T) {
 		t.Fatal(err)
 	}
 	assert.Equal(t, ref, crumb)
+
+	_, err = encrypter.ToCrumb("01000dRWRnZXdoZXJl")
+	var malformed encrypter.ErrMalformedCrumb
+	assert.Assert(t, errors.As(err, &malformed))
+	assert.Equal(t, malformed.Offset, 6)
+	assert.Error(t, err, "malformed crumb at offset 6: incompatible lengths")
+}
+
+// TestToCrumbWith ...
+func TestToCrumbWith(t *testing.T) {
+	layout := encrypter.Layout{Fingerprint: true}
+	ref := encrypter.Crumb{
+		Encrypted:   core.Base64("RWRnZXdoZXJl"),
+		Index:       1,
+		Length:      12,
+		Fingerprint: "8586d76f",
+	}
+	str := "018586d76f000cRWRnZXdoZXJl"
+	assert.Equal(t, ref.Stringify(layout), str)
+	assert.Equal(t, ref.String(), "01000cRWRnZXdoZXJl")
+	crumb, err := encrypter.ToCrumbWith(str, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ref, crumb)
+
+	_, err = encrypter.ToCrumbWith("01zz86d76f000cRWRnZXdoZXJl", layout)
+	assert.Error(t, err, "malformed crumb at offset 2: invalid fingerprint: encoding/hex: invalid byte: U+007A 'z'")
+
+	layout.VariableLength = true
+	str = "018586d76f1cRWRnZXdoZXJl"
+	assert.Equal(t, ref.Stringify(layout), str)
+	crumb, err = encrypter.ToCrumbWith(str, layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ref, crumb)
+
+	_, err = encrypter.ToCrumbWith("018586d76f0cRWRnZXdoZXJl", layout)
+	assert.Error(t, err, "malformed crumb at offset 10: invalid length width")
+
+	long := encrypter.Crumb{Index: 2, Length: 0x12345}
+	assert.Equal(t, long.Stringify(encrypter.Layout{VariableLength: true}), "02512345")
+	assert.Assert(t, encrypter.Layout{VariableLength: true}.Fits(long.Length))
+	assert.Assert(t, !encrypter.Layout{Fingerprint: true}.Fits(long.Length))
+}
+
+// TestParseCrumbs ...
+func TestParseCrumbs(t *testing.T) {
+	crumbs, err := encrypter.ParseCrumbs("008586d76f0004RWRn018586d76f0008ZXdoZXJl", encrypter.Layout{Fingerprint: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(crumbs), 2)
+	assert.Equal(t, crumbs[1].Index, 1)
+	assert.Equal(t, crumbs[1].Encrypted.String(), "ZXdoZXJl")
+
+	_, err = encrypter.ParseCrumbs("000004RWRn010009ZXdoZXJl", encrypter.Layout{})
+	var malformed encrypter.ErrMalformedCrumb
+	assert.Assert(t, errors.As(err, &malformed))
+	assert.Equal(t, malformed.Offset, 12)
+	assert.Equal(t, malformed.Reason, "length out of bounds")
+
+	crumbs, err = encrypter.ParseCrumbs("0014RWRn0118ZXdoZXJl", encrypter.Layout{VariableLength: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(crumbs), 2)
+	assert.Equal(t, crumbs[1].Encrypted.String(), "ZXdoZXJl")
+}
+
+// TestCrumbJSON ...
+func TestCrumbJSON(t *testing.T) {
+	ref := encrypter.Crumb{
+		Encrypted:   core.Base64("RWRnZXdoZXJl"),
+		Index:       1,
+		Length:      12,
+		Fingerprint: "8586d76f",
+	}
+	data, err := json.Marshal(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(data), `{"index":1,"fingerprint":"8586d76f","length":12,"ciphertext":"RWRnZXdoZXJl"}`)
+	var crumb encrypter.Crumb
+	err = json.Unmarshal(data, &crumb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, crumb, ref)
+
+	err = json.Unmarshal([]byte(`{"index":1,"length":13,"ciphertext":"RWRnZXdoZXJl"}`), &crumb)
+	assert.Error(t, err, "invalid crumb: incompatible lengths")
+	err = json.Unmarshal([]byte(`{"index":1,"length":12,"ciphertext":"not base64"}`), &crumb)
+	assert.Error(t, err, "invalid crumb: not a base64-encoded ciphertext")
 }
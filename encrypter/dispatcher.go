@@ -2,18 +2,24 @@ package encrypter
 
 import (
 	"errors"
+	"fmt"
 	"math/rand"
-	"time"
 
 	"github.com/cyrildever/crumbl-exe/models/signer"
 )
 
+const (
+	// DEFAULT_REDUNDANCY is the number of trustees signing each slice when more than three trustees are passed and no redundancy is set
+	DEFAULT_REDUNDANCY = 2
+)
+
 //--- TYPES
 
 // Dispatcher ...
 type Dispatcher struct {
 	NumberOfSlices int
 	Trustees       []signer.Signer
+	Redundancy     int // Optional: the number of trustees signing each slice
 }
 
 //--- METHODS
@@ -31,10 +37,15 @@ var (
 
 // Allocate returns a map of slice index -> trustees to sign, or an error if any.
 // It tries to uniformly distribute slices to trustees so that no trustee sign all slices and all slices are at least signed twice if possible.
+// Without any redundancy set, up to three trustees are allocated the historical way, any other number of trustees being allocated
+// with the DEFAULT_REDUNDANCY (see allocateCyclically).
 // Nota: the first slice (index 0) is reserved for data owners, so it should not be allocated.
 func (d *Dispatcher) Allocate() (map[int][]signer.Signer, error) {
-	allocation := make(map[int][]signer.Signer)
 	numberOfTrustees := len(d.Trustees)
+	if d.Redundancy != 0 || numberOfTrustees > 3 {
+		return d.allocateCyclically()
+	}
+	allocation := make(map[int][]signer.Signer)
 	switch numberOfTrustees {
 	case 1:
 		// All slices must be signed by the single trustee
@@ -54,7 +65,6 @@ func (d *Dispatcher) Allocate() (map[int][]signer.Signer, error) {
 		}
 	case 3:
 		// Slices must be allocated to n-1 trustees at most, and no trustee can have it all
-		rand.Seed(time.Now().UnixNano())
 		chosen := rand.Intn(len(combinationsFor3))
 		for i := 0; i < 3; i++ {
 			idx := combinationsFor3[chosen][i]
@@ -66,3 +76,44 @@ func (d *Dispatcher) Allocate() (map[int][]signer.Signer, error) {
 	}
 	return allocation, nil
 }
+
+// allocateCyclically shuffles the trustees then deals them in turn to each slice until it's signed by as many trustees as the redundancy.
+// The redundancy must be lower than the number of trustees (unless there's only one) for no trustee to sign all slices.
+func (d *Dispatcher) allocateCyclically() (map[int][]signer.Signer, error) {
+	numberOfTrustees := len(d.Trustees)
+	if numberOfTrustees == 0 {
+		return nil, errors.New("wrong number of trustees")
+	}
+	redundancy := d.Redundancy
+	if redundancy == 0 {
+		redundancy = DEFAULT_REDUNDANCY
+	}
+	if redundancy < 1 || (numberOfTrustees == 1 && redundancy > 1) || (numberOfTrustees > 1 && redundancy >= numberOfTrustees) {
+		return nil, fmt.Errorf("%w: %d for %d trustee(s)", ErrInvalidRedundancy, redundancy, numberOfTrustees)
+	}
+	allocation := make(map[int][]signer.Signer)
+	order := rand.Perm(numberOfTrustees)
+	held := make([]int, numberOfTrustees)
+	next := 0
+	for i := 1; i < d.NumberOfSlices; i++ {
+		for j := 0; j < redundancy; j++ {
+			t := order[next%numberOfTrustees]
+			allocation[i] = append(allocation[i], d.Trustees[t])
+			held[t]++
+			next++
+		}
+	}
+	if numberOfTrustees > 1 && d.NumberOfSlices > 2 {
+		for _, count := range held {
+			if count == d.NumberOfSlices-1 {
+				return nil, fmt.Errorf("%w: a trustee would sign all %d slices", ErrInvalidRedundancy, d.NumberOfSlices-1)
+			}
+		}
+	}
+	return allocation, nil
+}
+
+//--- ERRORS
+
+// ErrInvalidRedundancy is returned when the slices can't be allocated with the passed redundancy without a trustee signing them all
+var ErrInvalidRedundancy = errors.New("invalid redundancy")
@@ -2,6 +2,7 @@ package encrypter_test
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 
 	"github.com/cyrildever/crumbl-exe/encrypter"
@@ -31,3 +32,57 @@ func TestAllocate(t *testing.T) {
 	assert.Equal(t, len(allocation[3]), 2)
 	assert.Assert(t, !bytes.Equal(allocation[3][0].PublicKey, allocation[3][1].PublicKey))
 }
+
+// TestAllocateWithRedundancy ...
+func TestAllocateWithRedundancy(t *testing.T) {
+	var trustees []signer.Signer
+	for i := 1; i <= 5; i++ {
+		trustees = append(trustees, signer.Signer{PublicKey: []byte{byte(i)}})
+	}
+	for _, redundancy := range []int{0, 1, 2, 3, 4} {
+		d := encrypter.Dispatcher{
+			NumberOfSlices: 6,
+			Trustees:       trustees,
+			Redundancy:     redundancy,
+		}
+		allocation, err := d.Allocate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := redundancy
+		if expected == 0 {
+			expected = encrypter.DEFAULT_REDUNDANCY
+		}
+		held := make(map[byte]int)
+		for i := 1; i < 6; i++ {
+			assert.Equal(t, len(allocation[i]), expected)
+			distinct := make(map[byte]bool)
+			for _, trustee := range allocation[i] {
+				distinct[trustee.PublicKey[0]] = true
+				held[trustee.PublicKey[0]]++
+			}
+			assert.Equal(t, len(distinct), expected)
+		}
+		for _, count := range held {
+			assert.Assert(t, count < 5)
+		}
+	}
+
+	d := encrypter.Dispatcher{
+		NumberOfSlices: 6,
+		Trustees:       trustees,
+		Redundancy:     5,
+	}
+	_, err := d.Allocate()
+	assert.Assert(t, errors.Is(err, encrypter.ErrInvalidRedundancy))
+	assert.Error(t, err, "invalid redundancy: 5 for 5 trustee(s)")
+
+	// With fewer slices than trustees, a trustee can't sign them all either
+	d = encrypter.Dispatcher{
+		NumberOfSlices: 3,
+		Trustees:       trustees[:3],
+		Redundancy:     2,
+	}
+	_, err = d.Allocate()
+	assert.Assert(t, errors.Is(err, encrypter.ErrInvalidRedundancy))
+}
@@ -1,7 +1,7 @@
 package encrypter
 
 import (
-	"errors"
+	"fmt"
 
 	"github.com/cyrildever/crumbl-exe/crypto"
 	"github.com/cyrildever/crumbl-exe/crypto/ecies"
@@ -13,7 +13,7 @@ import (
 
 // Encrypt returns the base64-encoded encrypted slice as Crumb:
 // It takes the slice data and index as well as the signer as arguments,
-// and returns the corresponding Crumb object, or an error if any.
+// and returns the corresponding Crumb object holding the fingerprint of the signer's public key, or an error if any.
 func Encrypt(data slicer.Slice, index int, s signer.Signer) (c Crumb, err error) {
 	var enc []byte
 	switch s.EncryptionAlgorithm {
@@ -32,14 +32,19 @@ func Encrypt(data slicer.Slice, index int, s signer.Signer) (c Crumb, err error)
 		}
 		enc = crypted
 	default:
-		err = errors.New("unknown encryption algorithm: " + s.EncryptionAlgorithm)
+		err = fmt.Errorf("%w: %s", crypto.ErrUnknownAlgorithm, s.EncryptionAlgorithm)
+		return
+	}
+	fingerprint, err := crypto.Fingerprint(s.PublicKey, s.EncryptionAlgorithm)
+	if err != nil {
 		return
 	}
 	b64 := core.ToBase64(enc)
 	c = Crumb{
-		Encrypted: b64,
-		Index:     index,
-		Length:    len(b64.String()),
+		Encrypted:   b64,
+		Index:       index,
+		Length:      len(b64.String()),
+		Fingerprint: fingerprint,
 	}
 	return
 }
@@ -8,6 +8,7 @@ require (
 	github.com/cyrildever/feistel v1.5.14
 	github.com/cyrildever/go-utls v1.10.6
 	github.com/ethereum/go-ethereum v1.15.8
+	golang.org/x/crypto v0.37.0
 	gotest.tools v2.2.0+incompatible
 )
 
@@ -24,7 +25,6 @@ require (
 	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	go.mongodb.org/mongo-driver v1.17.3 // indirect
-	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/net v0.39.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
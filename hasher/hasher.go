@@ -12,7 +12,7 @@ import (
 )
 
 // The Hasher generates a modified hash unique to a source to prepend the crumbs in the finalized Crumbl.
-// It takes the datasource, hash it with the default hash engine, then uses the lexicographically sorted owner(s) crumb(s) encrypted part
+// It takes the datasource, hash it with the default (or the chosen) hash engine, then uses the lexicographically sorted owner(s) crumb(s) encrypted part
 // to XOR them with the final 32 characters of the hash. The final modified hash could therefore be represented by the following formula:
 //  N - 32 last chars of the hex string of the hashed source + (32 last chars ^ padded/cut lexicographically sorted owners encrypted crumbs).toHex
 
@@ -23,11 +23,22 @@ const (
 
 // Apply ...
 func Apply(source string, crumbs []encrypter.Crumb) (string, error) {
-	hSrc, err := crypto.Hash([]byte(source), crypto.DEFAULT_HASH_ENGINE)
+	return ApplyWithEngine(source, crumbs, crypto.DEFAULT_HASH_ENGINE)
+}
+
+// ApplyWithEngine does the same as Apply using the passed hash engine.
+// Unapply doesn't need it as only the last characters of the hash are modified.
+func ApplyWithEngine(source string, crumbs []encrypter.Crumb, engine string) (string, error) {
+	hSrc, err := crypto.Hash([]byte(source), engine)
 	if err != nil {
 		return "", err
 	}
-	stringifiedHash := utils.ToHex(hSrc)
+	return ApplyToHash(utils.ToHex(hSrc), crumbs)
+}
+
+// ApplyToHash does the same as Apply from the hexadecimal hash of the source, ie. the verification hash returned by Unapply,
+// so that the hashered value may be computed again for another set of owners' crumbs without the source.
+func ApplyToHash(stringifiedHash string, crumbs []encrypter.Crumb) (string, error) {
 	length := len(stringifiedHash)
 	if length < NUMBER_OF_CHARACTERS {
 		return "", errors.New("wrong hash algorithm")
@@ -40,7 +51,7 @@ func Apply(source string, crumbs []encrypter.Crumb) (string, error) {
 		}
 	}
 	if len(sortedOwnerCrumbs) == 0 {
-		return "", errors.New("owner's crumbs not present") // TODO Typed error
+		return "", ErrMissingOwnerCrumb
 	}
 	sort.Strings(sortedOwnerCrumbs)
 
@@ -75,7 +86,7 @@ func Unapply(hashered string, crumbs []encrypter.Crumb) (string, error) {
 		}
 	}
 	if len(sortedOwnerCrumbs) == 0 {
-		return "", errors.New("owner's crumbs not present") // TODO Typed error
+		return "", ErrMissingOwnerCrumb
 	}
 	sort.Strings(sortedOwnerCrumbs)
 
@@ -107,3 +118,8 @@ func buildMask(key []byte, length int) (mask []byte) {
 	}
 	return append(mask, key[:remainder]...)
 }
+
+//--- ERRORS
+
+// ErrMissingOwnerCrumb is returned when no crumb of index 0, ie. owned by a data owner, is found
+var ErrMissingOwnerCrumb = errors.New("owner's crumbs not present")
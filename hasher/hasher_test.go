@@ -1,6 +1,7 @@
 package hasher_test
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -35,6 +36,16 @@ func TestApply(t *testing.T) {
 	firstChars := utils.ToHex(hSrc)[:32]
 	assert.Assert(t, strings.HasPrefix(hashered, firstChars))
 
+	verificationHash, err := hasher.Unapply(hashered, crumbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reapplied, err := hasher.ApplyToHash(verificationHash, crumbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, reapplied, hashered)
+
 	_, err = hasher.Apply(source, []encrypter.Crumb{
 		{
 			Index:     1, // Wrong index
@@ -43,6 +54,7 @@ func TestApply(t *testing.T) {
 		},
 	})
 	assert.Error(t, err, "owner's crumbs not present")
+	assert.Assert(t, errors.Is(err, hasher.ErrMissingOwnerCrumb))
 }
 
 // TestUnapply ...
@@ -0,0 +1,333 @@
+package mailbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+	"github.com/cyrildever/crumbl-exe/utils"
+)
+
+// A mailbox is a directory shared between an owner and the trustees of its crumbls, eg. on a network drive or a synchronized folder:
+// - the owner posts an uncrumble request as `requests/<request id>.json`;
+// - each trustee answers the requests by dropping its signed response as `responses/<request id>/<fingerprint of its public key>.json`;
+// - the owner collects the responses signed by the trustees it knows to finalize the uncrumbling.
+// Every file is written to a temporary file first then renamed, so that no partial file is ever read.
+
+const (
+	// REQUESTS_DIRECTORY is the subdirectory of the mailbox holding the requests
+	REQUESTS_DIRECTORY = "requests"
+
+	// RESPONSES_DIRECTORY is the subdirectory of the mailbox holding the responses, one subdirectory per request
+	RESPONSES_DIRECTORY = "responses"
+
+	// REQUEST_ID_LENGTH is the number of hexadecimal characters of a request ID
+	REQUEST_ID_LENGTH = 32
+
+	// FILE_EXTENSION ...
+	FILE_EXTENSION = ".json"
+)
+
+//--- TYPES
+
+// Mailbox is the directory at Path.
+type Mailbox struct {
+	Path string
+}
+
+// Request asks the trustees for the partial uncrumbs of the crumbls, its ID being derived from them (see RequestID)
+type Request struct {
+	ID      string   `json:"id"`
+	Crumbls []string `json:"crumbls"`
+}
+
+// Response holds the partial uncrumbs of a trustee for the crumbls of a request, signed with the trustee's private key
+type Response struct {
+	RequestID       string   `json:"requestId"`
+	Trustee         string   `json:"trustee"` // The fingerprint of the trustee's public key
+	Algorithm       string   `json:"algorithm"`
+	PartialUncrumbs []string `json:"partialUncrumbs"` // One per crumbl of the request in the same order, empty if the trustee couldn't decipher it
+	Signature       string   `json:"signature"`       // The signature of the payload in hexadecimal (see crypto.Sign)
+}
+
+//--- METHODS
+
+// Post writes the request for the passed crumbls unless it already exists, and returns it
+func (m Mailbox) Post(crumbls []string) (req Request, err error) {
+	if len(crumbls) == 0 {
+		err = errors.New("no crumbl to request")
+		return
+	}
+	req = Request{
+		ID:      RequestID(crumbls),
+		Crumbls: crumbls,
+	}
+	filename := filepath.Join(m.Path, REQUESTS_DIRECTORY, req.ID+FILE_EXTENSION)
+	if _, e := os.Stat(filename); e == nil {
+		return
+	}
+	err = writeJSON(filename, req)
+	return
+}
+
+// Requests returns all the valid requests of the mailbox sorted by ID, the other ones being reported as errors in the returned list of invalid requests
+func (m Mailbox) Requests() (requests []Request, invalid []error, err error) {
+	entries, err := os.ReadDir(filepath.Join(m.Path, REQUESTS_DIRECTORY))
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), FILE_EXTENSION) {
+			continue
+		}
+		var req Request
+		if e := readJSON(filepath.Join(m.Path, REQUESTS_DIRECTORY, entry.Name()), &req); e != nil {
+			invalid = append(invalid, fmt.Errorf("%w: %s: %v", ErrInvalidRequest, entry.Name(), e))
+			continue
+		}
+		if len(req.Crumbls) == 0 || req.ID != RequestID(req.Crumbls) || req.ID+FILE_EXTENSION != entry.Name() {
+			invalid = append(invalid, fmt.Errorf("%w: %s: ID mismatch", ErrInvalidRequest, entry.Name()))
+			continue
+		}
+		requests = append(requests, req)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].ID < requests[j].ID
+	})
+	return
+}
+
+// Respond signs the passed partial uncrumbs of the trustee for the request and writes the response
+func (m Mailbox) Respond(req Request, trustee signer.Signer, partialUncrumbs []string) (res Response, err error) {
+	if len(partialUncrumbs) != len(req.Crumbls) {
+		err = errors.New("not as many partial uncrumbs as crumbls")
+		return
+	}
+	fingerprint, err := crypto.Fingerprint(trustee.PublicKey, trustee.EncryptionAlgorithm)
+	if err != nil {
+		return
+	}
+	res = Response{
+		RequestID:       req.ID,
+		Trustee:         fingerprint,
+		Algorithm:       trustee.EncryptionAlgorithm,
+		PartialUncrumbs: partialUncrumbs,
+	}
+	signature, err := crypto.Sign(res.payload(), trustee.PrivateKey, trustee.EncryptionAlgorithm)
+	if err != nil {
+		return
+	}
+	res.Signature = utils.ToHex(signature)
+	err = writeJSON(m.responseFilename(req.ID, fingerprint), res)
+	return
+}
+
+// Answer responds to every request of the mailbox the trustee hasn't answered yet, deciphering its crumbs with core.Uncrumbl,
+// and returns the number of answered requests. Invalid requests are only reported to the optional diagnostics writer,
+// and a request none of whose crumbls hold an uncrumb for the trustee is left unanswered, so that it's tried again on the next call.
+func (m Mailbox) Answer(trustee signer.Signer, diagnostics io.Writer) (answered int, err error) {
+	fingerprint, err := crypto.Fingerprint(trustee.PublicKey, trustee.EncryptionAlgorithm)
+	if err != nil {
+		return
+	}
+	requests, invalid, err := m.Requests()
+	if err != nil {
+		return
+	}
+	for _, e := range invalid {
+		warn(diagnostics, e.Error())
+	}
+	for _, req := range requests {
+		if _, e := os.Stat(m.responseFilename(req.ID, fingerprint)); e == nil {
+			continue
+		}
+		partialUncrumbs := make([]string, len(req.Crumbls))
+		deciphered := 0
+		for i, crumbled := range req.Crumbls {
+			uncrumbl := core.Uncrumbl{
+				Crumbled:    crumbled,
+				Signer:      trustee,
+				IsOwner:     false,
+				Diagnostics: io.Discard,
+			}
+			if vh, _, e := core.ExtractData(crumbled); e == nil {
+				uncrumbl.VerificationHash = vh
+			}
+			uncrumbled, e := uncrumbl.Process()
+			if e == nil {
+				// A valid crumbl may hold no crumb for the trustee
+				if partial, pe := core.ParsePartialUncrumbs(string(uncrumbled)); pe != nil || len(partial.Uncrumbs) == 0 {
+					e = errors.New("no uncrumb for the trustee")
+				}
+			}
+			if e != nil {
+				warn(diagnostics, fmt.Sprintf("unable to uncrumbl crumbl #%d of request %s: %v", i+1, req.ID, e))
+				continue
+			}
+			partialUncrumbs[i] = string(uncrumbled)
+			deciphered++
+		}
+		if deciphered == 0 {
+			warn(diagnostics, fmt.Sprintf("request %s left unanswered: no crumbl deciphered", req.ID))
+			continue
+		}
+		if _, err = m.Respond(req, trustee, partialUncrumbs); err != nil {
+			return
+		}
+		answered++
+	}
+	return
+}
+
+// Watch answers the requests of the mailbox every interval until the context is done, any failure being reported to the optional diagnostics writer
+func (m Mailbox) Watch(ctx context.Context, trustee signer.Signer, interval time.Duration, diagnostics io.Writer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := m.Answer(trustee, diagnostics); err != nil {
+			warn(diagnostics, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Responses returns the responses to the request signed by any of the passed trustees, the other ones being reported as errors
+// in the returned list of invalid responses, eg. a response whose filename isn't the fingerprint of the trustee it claims to come from.
+func (m Mailbox) Responses(requestID string, trustees []signer.Signer) (responses []Response, invalid []error, err error) {
+	known := make(map[string]signer.Signer)
+	for _, trustee := range trustees {
+		fingerprint, e := crypto.Fingerprint(trustee.PublicKey, trustee.EncryptionAlgorithm)
+		if e != nil {
+			err = e
+			return
+		}
+		known[fingerprint] = trustee
+	}
+	dir := filepath.Join(m.Path, RESPONSES_DIRECTORY, requestID)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), FILE_EXTENSION) {
+			continue
+		}
+		var res Response
+		if e := readJSON(filepath.Join(dir, entry.Name()), &res); e != nil {
+			invalid = append(invalid, fmt.Errorf("%w: %s: %v", ErrInvalidResponse, entry.Name(), e))
+			continue
+		}
+		if res.Trustee+FILE_EXTENSION != entry.Name() {
+			invalid = append(invalid, fmt.Errorf("%w: %s: trustee mismatch", ErrInvalidResponse, entry.Name()))
+			continue
+		}
+		if e := res.verify(requestID, known); e != nil {
+			invalid = append(invalid, fmt.Errorf("%w: %s: %v", ErrInvalidResponse, entry.Name(), e))
+			continue
+		}
+		responses = append(responses, res)
+	}
+	return
+}
+
+// payload returns the signed content of the response
+func (res Response) payload() []byte {
+	return []byte(strings.Join(append([]string{res.RequestID, res.Trustee, res.Algorithm}, res.PartialUncrumbs...), "\n"))
+}
+
+// verify checks that the response is for the passed request and signed by the known trustee it claims to come from
+func (res Response) verify(requestID string, known map[string]signer.Signer) error {
+	if res.RequestID != requestID {
+		return fmt.Errorf("response to request %s", res.RequestID)
+	}
+	trustee, found := known[res.Trustee]
+	if !found || trustee.EncryptionAlgorithm != res.Algorithm {
+		return fmt.Errorf("unknown trustee %s", res.Trustee)
+	}
+	signature, err := utils.FromHex(res.Signature)
+	if err != nil {
+		return crypto.ErrInvalidSignature
+	}
+	return crypto.Verify(res.payload(), signature, trustee.PublicKey, trustee.EncryptionAlgorithm)
+}
+
+func (m Mailbox) responseFilename(requestID, fingerprint string) string {
+	return filepath.Join(m.Path, RESPONSES_DIRECTORY, requestID, fingerprint+FILE_EXTENSION)
+}
+
+//--- FUNCTIONS
+
+// RequestID returns the ID of the request for the passed crumbls, ie. the first REQUEST_ID_LENGTH characters of the SHA-256 hash
+// of the crumbls, so that posting the same crumbls twice gives the same request.
+func RequestID(crumbls []string) string {
+	hash, _ := crypto.Hash([]byte(strings.Join(crumbls, "\n")), crypto.DEFAULT_HASH_ENGINE)
+	return utils.ToHex(hash)[:REQUEST_ID_LENGTH]
+}
+
+// writeJSON atomically writes the passed value to the file, creating its directory if need be
+func writeJSON(filename string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err = tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+func warn(diagnostics io.Writer, msg string) {
+	if diagnostics != nil {
+		fmt.Fprintf(diagnostics, "WARNING - %v\n", msg)
+	}
+}
+
+func readJSON(filename string, v interface{}) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+//--- ERRORS
+
+var (
+	// ErrInvalidRequest is returned when a request file of the mailbox is malformed
+	ErrInvalidRequest = errors.New("invalid mailbox request")
+
+	// ErrInvalidResponse is returned when a response file of the mailbox is malformed, unsigned or signed by an unknown trustee
+	ErrInvalidResponse = errors.New("invalid mailbox response")
+)
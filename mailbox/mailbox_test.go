@@ -0,0 +1,153 @@
+package mailbox_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/core"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/decrypter"
+	"github.com/cyrildever/crumbl-exe/mailbox"
+	"github.com/cyrildever/crumbl-exe/models/signer"
+
+	"gotest.tools/assert"
+)
+
+// TestMailbox ...
+func TestMailbox(t *testing.T) {
+	owner := loadSigner(t, "owner1")
+	trustee := loadSigner(t, "trustee1")
+	other := loadSigner(t, "signer")
+	crumbl := core.Crumbl{
+		Source:   "cdever@edgewhere.fr",
+		Owners:   []signer.Signer{{EncryptionAlgorithm: owner.EncryptionAlgorithm, PublicKey: owner.PublicKey}},
+		Trustees: []signer.Signer{{EncryptionAlgorithm: trustee.EncryptionAlgorithm, PublicKey: trustee.PublicKey}},
+	}
+	crumbled, err := crumbl.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The owner posts its request
+	m := mailbox.Mailbox{Path: t.TempDir()}
+	req, err := m.Post([]string{crumbled, "not-a-crumbl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, req.ID, mailbox.RequestID(req.Crumbls))
+	assert.Equal(t, len(req.ID), mailbox.REQUEST_ID_LENGTH)
+	os.WriteFile(filepath.Join(m.Path, mailbox.REQUESTS_DIRECTORY, "forged.json"), []byte(`{"id":"forged","crumbls":[]}`), 0644)
+
+	// The trustee answers it only once
+	var diagnostics bytes.Buffer
+	answered, err := m.Answer(trustee, &diagnostics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, answered, 1)
+	assert.Assert(t, strings.Contains(diagnostics.String(), "WARNING - invalid mailbox request: forged.json"))
+	assert.Assert(t, strings.Contains(diagnostics.String(), "WARNING - unable to uncrumbl crumbl #2 of request "+req.ID))
+	answered, err = m.Answer(trustee, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, answered, 0)
+
+	// A request none of whose crumbls is deciphered is tried again, eg. when addressed to other trustees
+	crumbl.Trustees = []signer.Signer{{EncryptionAlgorithm: other.EncryptionAlgorithm, PublicKey: other.PublicKey}}
+	forOther, err := crumbl.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreadable, err := m.Post([]string{"not-a-crumbl", forOther})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		diagnostics.Reset()
+		answered, err = m.Answer(trustee, &diagnostics)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, answered, 0)
+		assert.Assert(t, strings.Contains(diagnostics.String(), "WARNING - request "+unreadable.ID+" left unanswered"))
+		assert.Assert(t, strings.Contains(diagnostics.String(), "WARNING - unable to uncrumbl crumbl #2 of request "+unreadable.ID+": "+core.ErrNotATrustee.Error()))
+	}
+	_, err = os.Stat(filepath.Join(m.Path, mailbox.RESPONSES_DIRECTORY, unreadable.ID))
+	assert.Assert(t, os.IsNotExist(err))
+
+	// The owner collects the responses of the trustees it knows
+	responses, invalid, err := m.Responses(req.ID, []signer.Signer{trustee})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(invalid), 0)
+	assert.Equal(t, len(responses), 1)
+	assert.Equal(t, responses[0].PartialUncrumbs[1], "")
+	vh, _, _ := core.ExtractData(crumbled)
+	assert.Assert(t, strings.HasPrefix(responses[0].PartialUncrumbs[0], vh+decrypter.PARTIAL_PREFIX))
+
+	responses, invalid, err = m.Responses(req.ID, []signer.Signer{other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(responses), 0)
+	assert.Assert(t, errors.Is(invalid[0], mailbox.ErrInvalidResponse))
+
+	// A response filed under another trustee is rejected
+	filename := filepath.Join(m.Path, mailbox.RESPONSES_DIRECTORY, req.ID, fingerprintOf(t, trustee)+mailbox.FILE_EXTENSION)
+	data, _ := os.ReadFile(filename)
+	misfiled := filepath.Join(m.Path, mailbox.RESPONSES_DIRECTORY, req.ID, fingerprintOf(t, other)+mailbox.FILE_EXTENSION)
+	os.WriteFile(misfiled, data, 0644)
+	responses, invalid, err = m.Responses(req.ID, []signer.Signer{trustee, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(responses), 1)
+	assert.Assert(t, strings.HasSuffix(invalid[0].Error(), "trustee mismatch"))
+	os.Remove(misfiled)
+
+	// A tampered response is rejected
+	var res mailbox.Response
+	json.Unmarshal(data, &res)
+	res.PartialUncrumbs[1] = res.PartialUncrumbs[0]
+	data, _ = json.Marshal(res)
+	os.WriteFile(filename, data, 0644)
+	responses, invalid, err = m.Responses(req.ID, []signer.Signer{trustee})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(responses), 0)
+	assert.Assert(t, strings.HasSuffix(invalid[0].Error(), crypto.ErrInvalidSignature.Error()))
+}
+
+func loadSigner(t *testing.T, name string) signer.Signer {
+	pk, err := os.ReadFile("../crypto/ecies/keys/" + name + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := os.ReadFile("../crypto/ecies/keys/" + name + ".sk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubkey, _ := crypto.GetKeyBytes(strings.TrimSpace(string(pk)), crypto.ECIES_ALGORITHM)
+	privkey, _ := crypto.GetKeyBytes(strings.TrimSpace(string(sk)), crypto.ECIES_ALGORITHM)
+	return signer.Signer{
+		EncryptionAlgorithm: crypto.ECIES_ALGORITHM,
+		PublicKey:           pubkey,
+		PrivateKey:          privkey,
+	}
+}
+
+func fingerprintOf(t *testing.T, trustee signer.Signer) string {
+	fingerprint, err := crypto.Fingerprint(trustee.PublicKey, trustee.EncryptionAlgorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fingerprint
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/cyrildever/crumbl-exe/client"
+	"github.com/cyrildever/crumbl-exe/crypto"
+	"github.com/cyrildever/crumbl-exe/server"
 )
 
 /** Usage:
@@ -20,37 +27,144 @@ import (
  *	To decrypt crumbs as a signer:
  *	`./crumbl-exe -x -out myUncrumbs.txt --signer-keys ecies:edgewhere.pub --signer-secret edgewhere.sk <crumbled>`
  *
- *	As of the latest version, the library only processes one crumbl at a time.
+ *	To crumble again the source of a crumbl for new stakeholders as its owner, the source never being written in clear:
+ *	`./crumbl-exe -rekey -in theCrumbl.dat -out newCrumbl.dat --owner-keys ecies:myKey.pub --owner-secret myKey.sk --signer-keys ecies:edgewhere.pub --new-signer-keys ecies:newTrustee.pub <uncrumbs ...>`
+ *
+ *	To add an owner to a crumbl as one of its owners, without involving the trustees:
+ *	`./crumbl-exe -add-owner -in theCrumbl.dat -out newCrumbl.dat --owner-keys ecies:myKey.pub --owner-secret myKey.sk --new-owner-keys ecies:beneficiary.pub`
+ *
+ *	To remove an owner from every crumbl of a file, eg. when the owner's key is compromised:
+ *	`./crumbl-exe -revoke-owner -batch -in myCrumbls.dat -out newCrumbls.dat --owner-keys ecies:formerOwner.pub`
+ *
+ *	To describe a crumbl without any key, eg. to know which trustees to contact:
+ *	`./crumbl-exe -inspect -format json <crumbled>`
+ *
+ *	To sign the crumbls as their emitter, and only accept the crumbls it signed when extracting or inspecting:
+ *	`./crumbl-exe -c -out theCrumbl.dat --owner-keys ecies:myKey.pub --signer-keys ecies:edgewhere.pub --emitter-keys ecies:emitter.pub --emitter-secret emitter.sk cdever@edgewhere.fr`
+ *	`./crumbl-exe -inspect -in theCrumbl.dat --emitter-keys ecies:emitter.pub`
+ *
+ *	To generate a new key pair for a stakeholder (saved to myKey.sk and myKey.pub):
+ *	`./crumbl-exe -keygen ecies -out myKey`
+ *
+ *	To obfuscate the crumbls with a key of your own instead of the default one (generated to tenant.key):
+ *	`./crumbl-exe -keygen obfuscation -out tenant`
+ *	`./crumbl-exe -c -out theCrumbl.dat --owner-keys ecies:myKey.pub --signer-keys ecies:edgewhere.pub --obfuscation-keys tenant.key cdever@edgewhere.fr`
+ *
+ *	To save compact binary crumbls, which can later be passed as input file to -x or -inspect:
+ *	`./crumbl-exe -c -format binary -out theCrumbl.bin --owner-keys ecies:myKey.pub --signer-keys ecies:edgewhere.pub cdever@edgewhere.fr`
+ *
+ *	To stream sources (or crumbls) from stdin to stdout line by line, without any data on the command line:
+ *	`psql -Atc "SELECT email FROM users" | ./crumbl-exe -c -batch -in - --owner-keys ecies:myKey.pub --signer-keys ecies:edgewhere.pub | gzip > myCrumbls.gz`
+ *
+ *	To crumble the e-mail and phone columns of a CSV export, the other columns being left untouched (and back as the data owner):
+ *	`./crumbl-exe -c -csv email,phone -in export.csv -out crumbled.csv --owner-keys ecies:myKey.pub --signer-keys ecies:edgewhere.pub`
+ *	`./crumbl-exe -x -csv email,phone -in crumbled.csv -out export.csv --owner-keys ecies:myKey.pub --owner-secret myKey.sk <uncrumbs ...>`
+ *
+ *	To crumble nested fields of JSON Lines events, the rest of each document being left untouched:
+ *	`./crumbl-exe -c -json '$.customer.email,$.payments[*].iban' -in events.jsonl -out crumbled.jsonl --owner-keys ecies:myKey.pub --signer-keys ecies:edgewhere.pub`
+ *
+ *	To exchange with the trustees through a shared directory, the trusted signer answering the owner's requests every 30 seconds and the owner finalizing once they answered:
+ *	`./crumbl-exe -x -mailbox /shared/crumbl -poll 30s --signer-keys ecies:edgewhere.pub --signer-secret edgewhere.sk`
+ *	`./crumbl-exe -x -mailbox /shared/crumbl -in theCrumbl.dat --owner-keys ecies:myKey.pub --owner-secret myKey.sk --signer-keys ecies:edgewhere.pub`
+ *
+ *	To serve the crumbl creation and finalization endpoints as the data owner over HTTP, until interrupted (see the server package):
+ *	`./crumbl-exe serve -addr :8080 --owner-keys ecies:myKey.pub --owner-secret myKey.sk --signer-keys ecies:edgewhere.pub`
+ *	or the partial uncrumbling endpoint as a trusted signer:
+ *	`./crumbl-exe serve -addr :8080 --signer-keys ecies:edgewhere.pub --signer-secret edgewhere.sk`
+ *
+ *	To describe the stakeholders in a JSON keyring file instead of the key flags (see client.Keyring):
+ *	`./crumbl-exe -c -keyring stakeholders.json theSource`
+ *
+ *	To process an input file holding one source (or one crumbl followed by its partial uncrumbs) per line:
+ *	`./crumbl-exe -c -batch -in mySources.txt -out myCrumbls.dat --owner-keys ecies:myKey.pub --signer-keys ecies:edgewhere.pub`
  */
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serve(os.Args[2:])
+		return
+	}
+
 	// Define all flags
 	flag.Bool("c", false, "create a crumbled string from source")
 	flag.Bool("x", false, "extract crumbl(s)")
-	input := flag.String("in", "", "file to read an existing crumbl from (WARNING: do not add the crumbl string in the command-line arguments too)")
-	output := flag.String("out", "", "file to save result to")
+	flag.Bool("inspect", false, "describe crumbl(s) without decrypting them")
+	flag.Bool("add-owner", false, "add the owner(s) of --new-owner-keys to crumbl(s) as one of their owners, without involving the trustees")
+	flag.Bool("revoke-owner", false, "remove the owner(s) of --owner-keys from crumbl(s), no private key being needed but the emitter's for signed crumbls")
+	flag.Bool("rekey", false, "crumble again the source of crumbl(s) for the stakeholders of --new-owner-keys and --new-signer-keys as the owner, with the partial uncrumbs of the current trustees")
+	keygen := flag.String("keygen", "", "generate a key pair for the passed encryption algorithm (ecies or rsa) and save it to the -out path with .sk and .pub extensions, or an obfuscation key with the .key extension if set to obfuscation")
+	input := flag.String("in", "", "file to read the data from, or - to read it from stdin (WARNING: do not add the crumbl string in the command-line arguments too)")
+	output := flag.String("out", "", "file to append the result(s) to, or - for stdout (the default)")
+	batch := flag.Bool("batch", false, "process each line of the input file independently (one source, or one crumbl followed by its partial uncrumbs, per line)")
+	csvColumns := flag.String("csv", "", "comma-separated names (or positions starting at 1) of the columns to crumble or extract in the input CSV file, the other columns being copied as is")
+	csvNoHeader := flag.Bool("csv-no-header", false, "tell that the input CSV file has no header row, its columns being selected by position only")
+	jsonPaths := flag.String("json", "", "comma-separated JSON path selectors (eg. $.customer.email,$.payments[*].iban) of the string fields to crumble or extract in the JSON (or JSON Lines) input file, the rest of each document being left untouched")
+	mailboxPath := flag.String("mailbox", "", "directory shared with the trustees when extracting: the owner posts its request there and collects the signed responses of the trusted signers of --signer-keys, a trusted signer answers the pending requests")
+	poll := flag.Duration("poll", 0, "interval between two checks of the -mailbox as a trusted signer, eg. 30s (default: check once)")
+	workers := flag.Int("workers", 0, "number of concurrent workers in batch mode (default: the number of CPUs)")
 
 	ownerKeys := flag.String("owner-keys", "", "comma-separated list of colon-separated encryption algorithm prefix and filepath to public key of owner(s)")
-	signerKeys := flag.String("signer-keys", "", "comma-separated list of colon-separated encryption algorithm prefix and filepath to public key of trusted signer(s)")
+	signerKeys := flag.String("signer-keys", "", "comma-separated list of colon-separated encryption algorithm prefix and filepath to public key of trusted signer(s), whose signature of the partial uncrumbs is then checked when extracting as the owner")
+
+	newOwnerKeys := flag.String("new-owner-keys", "", "comma-separated list of colon-separated encryption algorithm prefix and filepath to public key of owner(s) of the rekeyed crumbl(s) (default: --owner-keys), or of the owner(s) to add with -add-owner")
+	newSignerKeys := flag.String("new-signer-keys", "", "comma-separated list of colon-separated encryption algorithm prefix and filepath to public key of trusted signer(s) of the rekeyed crumbl(s) (default: --signer-keys)")
 
 	ownerSecret := flag.String("owner-secret", "", "filepath to the private key of the owner")
 	signerSecret := flag.String("signer-secret", "", "filepath to the private key of the trusted signer")
 
+	keyring := flag.String("keyring", "", "filepath to a JSON keyring naming each stakeholder with its role (owner or trustee), encryption algorithm, public key and optional secret key, replacing --owner-keys, --owner-secret, --signer-keys and --signer-secret")
+
+	emitterKeys := flag.String("emitter-keys", "", "comma-separated list of colon-separated encryption algorithm prefix and filepath to public key of the authorized emitter(s), one of whom must have signed each crumbl to extract or inspect, or of the single emitter along with -emitter-secret when creating")
+	emitterSecret := flag.String("emitter-secret", "", "filepath to the private key of the emitter signing the crumbls when creating, rekeying, adding or revoking an owner")
+	obfuscationKeys := flag.String("obfuscation-keys", "", "comma-separated list of filepaths to obfuscation keys, the first one obfuscating the crumbls when creating or rekeying, all of them besides the default one being accepted when extracting or rekeying")
+
 	hash := flag.String("vh", "", "optional verification hash of the data")
 
+	threshold := flag.Int("threshold", 0, "number of trusted signers needed to extract the data when creating or rekeying, their slice being shared among them (default: all slices are needed)")
+	redundancy := flag.Int("redundancy", 0, "number of trusted signers signing each slice when creating or rekeying (default: 2 with more than three trusted signers)")
+
+	hashEngine := flag.String("hash-engine", crypto.DEFAULT_HASH_ENGINE, "hash engine of the verification hash when creating: sha-256, sha-512, sha3-256 or blake2b-512")
+
+	format := flag.String("format", string(client.TEXT_FORMAT), "output format: text, binary or json when creating, rekeying, adding or revoking an owner, text or json when extracting or inspecting")
+
+	bits := flag.Int("bits", crypto.DEFAULT_RSA_BITS, "size of the RSA key to generate with -keygen")
+
 	flag.Parse()
 
 	// Get data
 	data := flag.Args()
 
-	// Check operation: create or extract, ie. crumbl or uncrumbl
+	// Check operation: create, extract, inspect, rekey, add or revoke an owner, or generate keys
 	var mode client.CrumblMode
 	create := isFlagPassed("c")
 	extract := isFlagPassed("x")
-	if !create && !extract {
-		client.Check(errors.New("invalid operation: you must set -c or -x flag"), false)
+	inspect := isFlagPassed("inspect")
+	rekey := isFlagPassed("rekey")
+	addOwner := isFlagPassed("add-owner")
+	revokeOwner := isFlagPassed("revoke-owner")
+	generate := isFlagPassed("keygen")
+	operations := 0
+	for _, passed := range []bool{create, extract, inspect, rekey, addOwner, revokeOwner, generate} {
+		if passed {
+			operations++
+		}
+	}
+	if operations == 0 {
+		check(errors.New("invalid operation: you must set -c, -x, -inspect, -rekey, -add-owner, -revoke-owner or -keygen flag"), true)
+	}
+	if operations > 1 {
+		check(errors.New("invalid flags: only one operation at a time"), true)
+	}
+	if generate && *keygen == client.OBFUSCATION_ALGORITHM {
+		keyPath, err := client.GenerateObfuscationKeyFile(*output)
+		check(err, false)
+		fmt.Printf("SUCCESS - obfuscation key saved to %v\n", keyPath)
+		return
 	}
-	if create && extract {
-		client.Check(errors.New("invalid flags: cannot create and extract at the same time"), false)
+	if generate {
+		secretKeyPath, publicKeyPath, err := client.GenerateKeyFiles(*keygen, *bits, *output)
+		check(err, false)
+		fmt.Printf("SUCCESS - key pair saved to %v and %v\n", secretKeyPath, publicKeyPath)
+		return
 	}
 	if create {
 		mode = client.CREATION
@@ -58,6 +172,18 @@ func main() {
 	if extract {
 		mode = client.EXTRACTION
 	}
+	if inspect {
+		mode = client.INSPECTION
+	}
+	if rekey {
+		mode = client.REKEYING
+	}
+	if addOwner {
+		mode = client.DELEGATION
+	}
+	if revokeOwner {
+		mode = client.REVOCATION
+	}
 
 	// Launch worker
 	worker := client.CrumblWorker{
@@ -68,17 +194,82 @@ func main() {
 		OwnerSecret:      *ownerSecret,
 		SignerKeys:       *signerKeys,
 		SignerSecret:     *signerSecret,
+		Keyring:          *keyring,
 		VerificationHash: *hash,
 		Data:             data,
+		Batch:            *batch,
+		Workers:          *workers,
+		Redundancy:       *redundancy,
+		Threshold:        *threshold,
+		HashEngine:       *hashEngine,
+		EmitterKeys:      *emitterKeys,
+		EmitterSecret:    *emitterSecret,
+		NewOwnerKeys:     *newOwnerKeys,
+		NewSignerKeys:    *newSignerKeys,
+		ObfuscationKeys:  *obfuscationKeys,
+		Format:           client.Format(*format),
+		CSVColumns:       *csvColumns,
+		CSVNoHeader:      *csvNoHeader,
+		JSONPaths:        *jsonPaths,
+		Mailbox:          *mailboxPath,
+		Poll:             *poll,
 	}
 	_, err := worker.Process(false)
-	if err != nil {
-		panic(err)
-	}
+	check(err, false)
+}
+
+// serve runs the HTTP server with the passed arguments until the process is interrupted
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	address := flags.String("addr", server.DEFAULT_ADDRESS, "address to listen on")
+	ownerKeys := flags.String("owner-keys", "", "comma-separated list of colon-separated encryption algorithm prefix and filepath to public key of owner(s) of the crumbls to create, or of the single owner along with -owner-secret")
+	ownerKey := flags.String("owner-key", "", "colon-separated encryption algorithm prefix and filepath to public key of the owner serving POST /uncrumbl along with -owner-secret, needed if -owner-keys holds several keys")
+	ownerSecret := flags.String("owner-secret", "", "filepath to the private key of the owner, to serve POST /uncrumbl")
+	signerKeys := flags.String("signer-keys", "", "comma-separated list of colon-separated encryption algorithm prefix and filepath to public key of trusted signer(s) of the crumbls to create and of the partial uncrumbs to accept, or of the single trusted signer along with -signer-secret")
+	signerKey := flags.String("signer-key", "", "colon-separated encryption algorithm prefix and filepath to public key of the trusted signer serving POST /partial along with -signer-secret, needed if -signer-keys holds several keys")
+	signerSecret := flags.String("signer-secret", "", "filepath to the private key of the trusted signer, to serve POST /partial")
+	emitterKeys := flags.String("emitter-keys", "", "comma-separated list of colon-separated encryption algorithm prefix and filepath to public key of the authorized emitter(s) of the crumbls to uncrumbl, or of the single emitter along with -emitter-secret")
+	emitterSecret := flags.String("emitter-secret", "", "filepath to the private key of the emitter signing the crumbls created by POST /crumbl")
+	obfuscationKeys := flags.String("obfuscation-keys", "", "comma-separated list of filepaths to obfuscation keys, the first one obfuscating the crumbls created by POST /crumbl, all of them besides the default one being accepted when uncrumbling")
+	maxBodySize := flags.Int64("max-body-size", server.DEFAULT_MAX_BODY_SIZE, "maximum size in bytes of a request body")
+	workers := flags.Int("workers", 0, "number of concurrent workers per request (default: the number of CPUs)")
+	flags.Parse(args)
+
+	s, err := server.NewServer(server.Config{
+		Address:         *address,
+		OwnerKeys:       *ownerKeys,
+		OwnerKey:        *ownerKey,
+		OwnerSecret:     *ownerSecret,
+		SignerKeys:      *signerKeys,
+		SignerKey:       *signerKey,
+		SignerSecret:    *signerSecret,
+		EmitterKeys:     *emitterKeys,
+		EmitterSecret:   *emitterSecret,
+		ObfuscationKeys: *obfuscationKeys,
+		MaxBodySize:     *maxBodySize,
+		Workers:         *workers,
+		Diagnostics:     os.Stderr,
+	})
+	check(err, false)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Fprintf(os.Stderr, "INFO - serving on %v\n", *address)
+	check(s.ListenAndServe(ctx), false)
 }
 
 //--- utilities
 
+// check exits the process after printing the passed error to stderr, along with the usage if need be
+func check(err error, withUsage bool) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR - %v\n", err)
+		if withUsage {
+			flag.Usage()
+		}
+		os.Exit(1)
+	}
+}
+
 func isFlagPassed(name string) bool {
 	found := false
 	flag.Visit(func(f *flag.Flag) {
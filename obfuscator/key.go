@@ -0,0 +1,143 @@
+package obfuscator
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cyrildever/feistel"
+	"github.com/cyrildever/feistel/common/utils/hash"
+)
+
+// The text form of a key is made of its hash engine, its number of rounds and its key string separated by colons,
+// eg. `sha-256:10:8ed9dcc1...`, or only of its key string to use the default hash engine and number of rounds.
+
+const (
+	// KEY_ID_LENGTH is the number of hexadecimal characters of a key ID
+	KEY_ID_LENGTH = 8
+
+	// MIN_ROUNDS is the minimum number of rounds of the Feistel cipher
+	MIN_ROUNDS = 2
+)
+
+//--- TYPES
+
+// Key holds the parameters of the Feistel cipher of an obfuscator, so that each deployment may use its own.
+// Its zero value stands for the default key.
+type Key struct {
+	HashEngine hash.Engine
+	Key        string
+	Rounds     int
+}
+
+//--- METHODS
+
+// Cipher returns the Feistel cipher of the key
+func (k Key) Cipher() *feistel.FPECipher {
+	k = k.orDefault()
+	return feistel.NewFPECipher(k.HashEngine, k.Key, k.Rounds)
+}
+
+// Check returns an ErrInvalidKey if the key can't be used by a Feistel cipher
+func (k Key) Check() error {
+	k = k.orDefault()
+	switch {
+	case !hash.IsAvailableEngine(k.HashEngine):
+		return fmt.Errorf("%w: unknown hash engine %s", ErrInvalidKey, k.HashEngine)
+	case k.Rounds < MIN_ROUNDS:
+		return fmt.Errorf("%w: at least %d rounds expected", ErrInvalidKey, MIN_ROUNDS)
+	case k.Key == "" || strings.ContainsAny(k.Key, ": \t\r\n"):
+		return fmt.Errorf("%w: empty or malformed key string", ErrInvalidKey)
+	}
+	return nil
+}
+
+// ID returns the identifier of the key to reference it in a crumbl, ie. the first KEY_ID_LENGTH characters of the SHA-256 hash of its text form
+func (k Key) ID() string {
+	hash := sha256.Sum256([]byte(k.orDefault().String()))
+	return hex.EncodeToString(hash[:])[:KEY_ID_LENGTH]
+}
+
+// IsDefault tells whether the key is the default one
+func (k Key) IsDefault() bool {
+	return k.orDefault() == DefaultKey()
+}
+
+// String returns the text form of the key
+func (k Key) String() string {
+	return string(k.HashEngine) + ":" + strconv.Itoa(k.Rounds) + ":" + k.Key
+}
+
+func (k Key) orDefault() Key {
+	if k == (Key{}) {
+		return DefaultKey()
+	}
+	return k
+}
+
+//--- FUNCTIONS
+
+// DefaultKey returns the key used when none is configured, ie. the one of every crumbl up to version 7
+func DefaultKey() Key {
+	return Key{
+		HashEngine: DEFAULT_HASH_ENGINE,
+		Key:        DEFAULT_KEY_STRING,
+		Rounds:     DEFAULT_ROUNDS,
+	}
+}
+
+// GenerateKey returns a new key made of 32 random bytes with the default hash engine and number of rounds
+func GenerateKey() (k Key, err error) {
+	random := make([]byte, 32)
+	if _, err = rand.Read(random); err != nil {
+		return
+	}
+	k = DefaultKey()
+	k.Key = hex.EncodeToString(random)
+	return
+}
+
+// ParseKey returns the key from its text form, checking it can be used
+func ParseKey(str string) (k Key, err error) {
+	parts := strings.Split(strings.TrimSpace(str), ":")
+	switch len(parts) {
+	case 1:
+		k = DefaultKey()
+		k.Key = parts[0]
+	case 3:
+		rounds, e := strconv.Atoi(parts[1])
+		if e != nil {
+			err = fmt.Errorf("%w: invalid rounds %s", ErrInvalidKey, parts[1])
+			return
+		}
+		k = Key{
+			HashEngine: hash.Engine(parts[0]),
+			Key:        parts[2],
+			Rounds:     rounds,
+		}
+	default:
+		err = fmt.Errorf("%w: malformed key", ErrInvalidKey)
+		return
+	}
+	if e := k.Check(); e != nil {
+		return Key{}, e
+	}
+	return
+}
+
+// NewObfuscatorWith returns an obfuscator using the passed key, or an error if the key can't be used
+func NewObfuscatorWith(k Key) (*Obfuscator, error) {
+	if err := k.Check(); err != nil {
+		return nil, err
+	}
+	return NewObfuscator(k.Cipher()), nil
+}
+
+//--- ERRORS
+
+// ErrInvalidKey is returned when the parameters of an obfuscation key can't be used
+var ErrInvalidKey = errors.New("invalid obfuscation key")
@@ -0,0 +1,60 @@
+package obfuscator_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/obfuscator"
+	"gotest.tools/assert"
+)
+
+// TestParseKey ...
+func TestParseKey(t *testing.T) {
+	key, err := obfuscator.ParseKey("keccak-256:12:8ed9dcc1701c064f0fd7ae235f15143f989920e0ee9658bb7882c8d7d5f05692\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, key.Rounds, 12)
+	assert.Equal(t, key.String(), "keccak-256:12:8ed9dcc1701c064f0fd7ae235f15143f989920e0ee9658bb7882c8d7d5f05692")
+	assert.Equal(t, len(key.ID()), obfuscator.KEY_ID_LENGTH)
+	assert.Assert(t, !key.IsDefault())
+
+	short, err := obfuscator.ParseKey(obfuscator.DEFAULT_KEY_STRING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Assert(t, short.IsDefault())
+	assert.Assert(t, obfuscator.Key{}.IsDefault())
+	assert.Equal(t, obfuscator.Key{}.ID(), obfuscator.DefaultKey().ID())
+
+	for _, invalid := range []string{"", "a:b", "sha-256:ten:key", "sha-256:1:key", "md4:10:key"} {
+		_, err = obfuscator.ParseKey(invalid)
+		assert.Assert(t, errors.Is(err, obfuscator.ErrInvalidKey), invalid)
+	}
+}
+
+// TestNewObfuscatorWith ...
+func TestNewObfuscatorWith(t *testing.T) {
+	key, err := obfuscator.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := obfuscator.NewObfuscatorWith(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obfuscated, err := o.Apply("Edgewhere")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaulted, _ := obfuscator.NewObfuscator(cipher).Apply("Edgewhere")
+	assert.Assert(t, string(obfuscated) != string(defaulted))
+	deobfuscated, err := o.Unapply(obfuscated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, deobfuscated, "Edgewhere")
+
+	_, err = obfuscator.NewObfuscatorWith(obfuscator.Key{Key: "key", Rounds: 10})
+	assert.Assert(t, errors.Is(err, obfuscator.ErrInvalidKey))
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cyrildever/crumbl-exe/client"
+)
+
+// The server exposes the following endpoints, each taking and returning a JSON object:
+// - `POST /crumbl` creates the crumbl of each source for the configured owners and trustees;
+// - `POST /partial` returns the partial uncrumbs of each crumbl deciphered with the configured trustee's secret key;
+// - `POST /uncrumbl` returns the source of each crumbl deciphered with the configured owner's secret key and the passed partial uncrumbs,
+// only accepting those signed by the configured trustees if any.
+// An endpoint is only served when the keys it needs are configured, eg. a trustee only serves `/partial`, and its keys are read and checked
+// once when creating the server, which fails on any invalid one.
+// As the server doesn't authenticate its clients, it should only be reachable through a gateway that does, all the more when it serves `/uncrumbl`.
+
+const (
+	// DEFAULT_ADDRESS is the address the server listens on if none is configured
+	DEFAULT_ADDRESS = ":8080"
+
+	// DEFAULT_MAX_BODY_SIZE is the maximum size in bytes of a request body if none is configured
+	DEFAULT_MAX_BODY_SIZE = 1 << 20
+
+	// SHUTDOWN_TIMEOUT is the time left to the pending requests to complete once the server is asked to stop
+	SHUTDOWN_TIMEOUT = 10 * time.Second
+)
+
+//--- TYPES
+
+// Config holds the address and the keys of the server, the keys following the format of the executable flags.
+type Config struct {
+	Address         string    // Optional: defaults to DEFAULT_ADDRESS
+	OwnerKeys       string    // The public keys of the owners of the crumbls to create, or of the single owner finalizing them along with OwnerSecret
+	OwnerKey        string    // Optional: the public key of the owner serving `/uncrumbl` along with OwnerSecret, needed if OwnerKeys holds several keys
+	OwnerSecret     string    // Optional: the filepath to the owner's private key, to serve `/uncrumbl`
+	SignerKeys      string    // The public keys of the trustees of the crumbls to create and of the partial uncrumbs to accept, or of the single trustee along with SignerSecret
+	SignerKey       string    // Optional: the public key of the trustee serving `/partial` along with SignerSecret, needed if SignerKeys holds several keys
+	SignerSecret    string    // Optional: the filepath to the trustee's private key, to serve `/partial`
+	EmitterKeys     string    // Optional: the public keys of the authorized emitters of the crumbls to uncrumbl, or of the single emitter along with EmitterSecret
+	EmitterSecret   string    // Optional: the filepath to the emitter's private key, to sign the crumbls created by `/crumbl`
+	ObfuscationKeys string    // Optional: the filepaths to the obfuscation keys, the first one obfuscating the crumbls created by `/crumbl`
+	MaxBodySize     int64     // Optional: the maximum size in bytes of a request body, defaults to DEFAULT_MAX_BODY_SIZE
+	Workers         int       // Optional: the number of concurrent workers per request, defaults to the number of CPUs
+	Diagnostics     io.Writer // Optional destination of warnings
+}
+
+// Server is an HTTP server to create and decipher crumbls.
+type Server struct {
+	config       Config
+	handler      http.Handler
+	crumblKeys   *client.Keys
+	partialKeys  *client.Keys
+	uncrumblKeys *client.Keys
+}
+
+// CrumblRequest is the body of a `POST /crumbl` request
+type CrumblRequest struct {
+	Sources    []string `json:"sources"`
+	HashEngine string   `json:"hashEngine,omitempty"`
+	Threshold  int      `json:"threshold,omitempty"`
+	Redundancy int      `json:"redundancy,omitempty"`
+}
+
+// UncrumblRequest is the body of a `POST /partial` or `POST /uncrumbl` request
+type UncrumblRequest struct {
+	Crumbls         []string `json:"crumbls"`
+	PartialUncrumbs []string `json:"partialUncrumbs,omitempty"` // The partial uncrumbs collected from the trustees, only used by `/uncrumbl`
+}
+
+// Response is the body of any response, holding either the results in the same order as the passed sources or crumbls or the error of the request
+type Response struct {
+	Results []Result `json:"results,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Result is either the value or the error for a source or a crumbl of the request, ie. its crumbl, its partial uncrumbs or its source
+type Result struct {
+	Value string `json:"value,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+//--- METHODS
+
+// Handler returns the handler of the configured endpoints, eg. to use with httptest
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// ListenAndServe serves the configured endpoints until the passed context is done, then shuts the server down gracefully,
+// letting the pending requests complete within SHUTDOWN_TIMEOUT.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.config.Address,
+		Handler:           s.handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) crumbl(w http.ResponseWriter, r *http.Request) {
+	var req CrumblRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if len(req.Sources) == 0 {
+		respond(w, http.StatusBadRequest, Response{Error: "no source to crumbl"})
+		return
+	}
+	results, err := client.Crumble(r.Context(), client.Options{
+		Keys:        s.crumblKeys,
+		Sources:     req.Sources,
+		HashEngine:  req.HashEngine,
+		Threshold:   req.Threshold,
+		Redundancy:  req.Redundancy,
+		Workers:     s.config.Workers,
+		Diagnostics: s.config.Diagnostics,
+	})
+	s.reply(w, results, err)
+}
+
+func (s *Server) partial(w http.ResponseWriter, r *http.Request) {
+	var req UncrumblRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if len(req.Crumbls) == 0 {
+		respond(w, http.StatusBadRequest, Response{Error: "no crumbl to uncrumbl"})
+		return
+	}
+	results, err := client.Uncrumble(r.Context(), client.Options{
+		Keys:        s.partialKeys,
+		Crumbls:     req.Crumbls,
+		Workers:     s.config.Workers,
+		Diagnostics: s.config.Diagnostics,
+	})
+	s.reply(w, results, err)
+}
+
+func (s *Server) uncrumbl(w http.ResponseWriter, r *http.Request) {
+	var req UncrumblRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	if len(req.Crumbls) == 0 {
+		respond(w, http.StatusBadRequest, Response{Error: "no crumbl to uncrumbl"})
+		return
+	}
+	results, err := client.Uncrumble(r.Context(), client.Options{
+		Keys:            s.uncrumblKeys,
+		Crumbls:         req.Crumbls,
+		PartialUncrumbs: req.PartialUncrumbs,
+		Workers:         s.config.Workers,
+		Diagnostics:     s.config.Diagnostics,
+	})
+	s.reply(w, results, err)
+}
+
+// decode reads the JSON body of the request, replying with an error if it's too large or malformed
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.config.MaxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respond(w, http.StatusRequestEntityTooLarge, Response{Error: fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit)})
+		} else {
+			respond(w, http.StatusBadRequest, Response{Error: "invalid request body: " + err.Error()})
+		}
+		return false
+	}
+	return true
+}
+
+// reply sends the results in the same order as the items of the request, or an error if the request couldn't be processed at all,
+// ie. a bad request if its parameters are invalid and an internal error otherwise
+func (s *Server) reply(w http.ResponseWriter, results []client.Result, err error) {
+	if errors.Is(err, client.ErrInvalidOptions) {
+		respond(w, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		respond(w, http.StatusInternalServerError, Response{Error: err.Error()})
+		return
+	}
+	res := Response{Results: make([]Result, len(results))}
+	for _, result := range results {
+		if result.Err != nil {
+			res.Results[result.Index].Error = result.Err.Error()
+		} else {
+			res.Results[result.Index].Value = result.Value
+		}
+	}
+	respond(w, http.StatusOK, res)
+}
+
+//--- FUNCTIONS
+
+// NewServer returns a server for the passed configuration after reading and checking the keys of its endpoints,
+// or an error if any key is invalid or if it has no endpoint to serve.
+func NewServer(config Config) (s *Server, err error) {
+	if config.Address == "" {
+		config.Address = DEFAULT_ADDRESS
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DEFAULT_MAX_BODY_SIZE
+	}
+	s = &Server{config: config}
+	mux := http.NewServeMux()
+	served := false
+	if config.OwnerKeys != "" && config.SignerKeys != "" {
+		s.crumblKeys, err = client.LoadKeys(client.Options{
+			OwnerKeys:       config.OwnerKeys,
+			SignerKeys:      config.SignerKeys,
+			EmitterKeys:     config.EmitterKeys,
+			EmitterSecret:   config.EmitterSecret,
+			ObfuscationKeys: config.ObfuscationKeys,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("POST /crumbl: %w", err)
+		}
+		mux.HandleFunc("POST /crumbl", s.crumbl)
+		served = true
+	}
+	if config.SignerSecret != "" {
+		signerKey, e := servingKey(config.SignerKey, config.SignerKeys, "trusted signer")
+		if e == nil {
+			s.partialKeys, e = client.LoadKeys(client.Options{
+				SignerKeys:      signerKey,
+				SignerSecret:    config.SignerSecret,
+				EmitterKeys:     config.EmitterKeys,
+				ObfuscationKeys: config.ObfuscationKeys,
+			})
+		}
+		if e != nil {
+			return nil, fmt.Errorf("POST /partial: %w", e)
+		}
+		mux.HandleFunc("POST /partial", s.partial)
+		served = true
+	}
+	if config.OwnerSecret != "" {
+		ownerKey, e := servingKey(config.OwnerKey, config.OwnerKeys, "owner")
+		if e == nil {
+			s.uncrumblKeys, e = client.LoadKeys(client.Options{
+				OwnerKeys:       ownerKey,
+				OwnerSecret:     config.OwnerSecret,
+				SignerKeys:      config.SignerKeys,
+				EmitterKeys:     config.EmitterKeys,
+				ObfuscationKeys: config.ObfuscationKeys,
+			})
+		}
+		if e != nil {
+			return nil, fmt.Errorf("POST /uncrumbl: %w", e)
+		}
+		mux.HandleFunc("POST /uncrumbl", s.uncrumbl)
+		served = true
+	}
+	if !served {
+		return nil, errors.New("no endpoint to serve: missing keys")
+	}
+	s.handler = mux
+	return s, nil
+}
+
+// servingKey returns the public key selected for the owner or the trustee serving an endpoint,
+// defaulting to the list of public keys of its role only if it holds a single one
+func servingKey(key, keys, role string) (string, error) {
+	if key != "" {
+		if strings.Contains(key, ",") {
+			return "", fmt.Errorf("a single public key is expected for the %s", role)
+		}
+		return key, nil
+	}
+	if keys == "" {
+		return "", fmt.Errorf("missing public key of the %s", role)
+	}
+	if strings.Contains(keys, ",") {
+		return "", fmt.Errorf("several public keys: the one of the %s must be selected", role)
+	}
+	return keys, nil
+}
+
+func respond(w http.ResponseWriter, status int, res Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,202 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyrildever/crumbl-exe/server"
+
+	"gotest.tools/assert"
+)
+
+var dir, _ = getHomeDirectory()
+
+// TestServer ...
+func TestServer(t *testing.T) {
+	owner, err := server.NewServer(server.Config{
+		OwnerKeys:   "ecies:" + dir + "crypto/ecies/keys/owner1.pub",
+		OwnerSecret: dir + "crypto/ecies/keys/owner1.sk",
+		SignerKeys:  "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerServer := httptest.NewServer(owner.Handler())
+	defer ownerServer.Close()
+	trustee, err := server.NewServer(server.Config{
+		SignerKeys:   "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+		MaxBodySize:  4096,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusteeServer := httptest.NewServer(trustee.Handler())
+	defer trusteeServer.Close()
+
+	// Creation
+	sources := []string{"cdever@edgewhere.fr", "contact@edgewhere.fr"}
+	status, crumbls := post(t, ownerServer.URL+"/crumbl", server.CrumblRequest{Sources: sources})
+	assert.Equal(t, status, http.StatusOK)
+	assert.Equal(t, len(crumbls.Results), 2)
+
+	// Partial uncrumbling by the trustee
+	status, partials := post(t, trusteeServer.URL+"/partial", server.UncrumblRequest{
+		Crumbls: []string{crumbls.Results[0].Value, "not-a-crumbl", crumbls.Results[1].Value},
+	})
+	assert.Equal(t, status, http.StatusOK)
+	assert.Assert(t, partials.Results[1].Error != "")
+
+	// Finalization by the owner
+	status, uncrumbled := post(t, ownerServer.URL+"/uncrumbl", server.UncrumblRequest{
+		Crumbls:         []string{crumbls.Results[1].Value, crumbls.Results[0].Value},
+		PartialUncrumbs: []string{partials.Results[0].Value, partials.Results[2].Value},
+	})
+	assert.Equal(t, status, http.StatusOK)
+	assert.Equal(t, uncrumbled.Results[0].Value, sources[1])
+	assert.Equal(t, uncrumbled.Results[1].Value, sources[0])
+
+	// Invalid requests
+	status, res := post(t, trusteeServer.URL+"/crumbl", server.CrumblRequest{Sources: sources})
+	assert.Equal(t, status, http.StatusNotFound)
+	status, res = post(t, ownerServer.URL+"/crumbl", server.CrumblRequest{})
+	assert.Equal(t, status, http.StatusBadRequest)
+	assert.Equal(t, res.Error, "no source to crumbl")
+	status, res = post(t, ownerServer.URL+"/crumbl", map[string]string{"source": sources[0]})
+	assert.Equal(t, status, http.StatusBadRequest)
+	status, res = post(t, ownerServer.URL+"/crumbl", server.CrumblRequest{Sources: sources, HashEngine: "md5"})
+	assert.Equal(t, status, http.StatusBadRequest)
+	assert.Equal(t, res.Error, "invalid options: invalid hash engine: md5")
+	status, _ = post(t, ownerServer.URL+"/crumbl", server.CrumblRequest{Sources: sources, Threshold: 2})
+	assert.Equal(t, status, http.StatusBadRequest)
+	status, _ = post(t, ownerServer.URL+"/crumbl", server.CrumblRequest{Sources: sources, Redundancy: -1})
+	assert.Equal(t, status, http.StatusBadRequest)
+	status, res = post(t, trusteeServer.URL+"/partial", server.UncrumblRequest{Crumbls: []string{strings.Repeat("0", 5000)}})
+	assert.Equal(t, status, http.StatusRequestEntityTooLarge)
+	assert.Equal(t, res.Error, "request body larger than 4096 bytes")
+	resp, err := http.Get(ownerServer.URL + "/crumbl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusMethodNotAllowed)
+
+	_, err = server.NewServer(server.Config{OwnerKeys: "ecies:" + dir + "crypto/ecies/keys/owner1.pub"})
+	assert.Error(t, err, "no endpoint to serve: missing keys")
+}
+
+// TestServerIdentity ...
+func TestServerIdentity(t *testing.T) {
+	keys := dir + "crypto/ecies/keys/"
+	config := server.Config{
+		OwnerKeys:    "ecies:" + keys + "owner1.pub,ecies:" + keys + "signer.pub",
+		OwnerKey:     "ecies:" + keys + "owner1.pub",
+		OwnerSecret:  keys + "owner1.sk",
+		SignerKeys:   "ecies:" + keys + "trustee1.pub,rsa:" + dir + "crypto/rsa/keys/trustee2.pub",
+		SignerKey:    "ecies:" + keys + "trustee1.pub",
+		SignerSecret: keys + "trustee1.sk",
+	}
+	s, err := server.NewServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	trustee2, err := server.NewServer(server.Config{
+		SignerKeys:   "rsa:" + dir + "crypto/rsa/keys/trustee2.pub",
+		SignerSecret: dir + "crypto/rsa/keys/trustee2.sk",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts2 := httptest.NewServer(trustee2.Handler())
+	defer ts2.Close()
+
+	// Several owners and trustees, the served ones being selected
+	source := "cdever@edgewhere.fr"
+	status, crumbls := post(t, ts.URL+"/crumbl", server.CrumblRequest{Sources: []string{source}})
+	assert.Equal(t, status, http.StatusOK)
+	status, partials := post(t, ts.URL+"/partial", server.UncrumblRequest{Crumbls: []string{crumbls.Results[0].Value}})
+	assert.Equal(t, status, http.StatusOK)
+	assert.Equal(t, partials.Results[0].Error, "")
+	status, partials2 := post(t, ts2.URL+"/partial", server.UncrumblRequest{Crumbls: []string{crumbls.Results[0].Value}})
+	assert.Equal(t, status, http.StatusOK)
+	status, uncrumbled := post(t, ts.URL+"/uncrumbl", server.UncrumblRequest{
+		Crumbls:         []string{crumbls.Results[0].Value},
+		PartialUncrumbs: []string{partials.Results[0].Value, partials2.Results[0].Value},
+	})
+	assert.Equal(t, status, http.StatusOK)
+	assert.Equal(t, uncrumbled.Results[0].Value, source)
+
+	// The keys are checked at startup
+	ambiguous := config
+	ambiguous.OwnerKey = ""
+	_, err = server.NewServer(ambiguous)
+	assert.Error(t, err, "POST /uncrumbl: several public keys: the one of the owner must be selected")
+	ambiguous = config
+	ambiguous.SignerKey = ""
+	_, err = server.NewServer(ambiguous)
+	assert.Error(t, err, "POST /partial: several public keys: the one of the trusted signer must be selected")
+	wrongSecret := config
+	wrongSecret.OwnerSecret = keys + "missing.sk"
+	_, err = server.NewServer(wrongSecret)
+	assert.ErrorContains(t, err, "POST /uncrumbl: ")
+	wrongKey := config
+	wrongKey.SignerKeys += ",ecies:wrong/path.pub"
+	_, err = server.NewServer(wrongKey)
+	assert.Error(t, err, "POST /crumbl: invalid keys: invalid file path in ecies:wrong/path.pub")
+}
+
+// TestListenAndServe ...
+func TestListenAndServe(t *testing.T) {
+	s, err := server.NewServer(server.Config{
+		Address:      "127.0.0.1:0",
+		SignerKeys:   "ecies:" + dir + "crypto/ecies/keys/trustee1.pub",
+		SignerSecret: dir + "crypto/ecies/keys/trustee1.sk",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.ListenAndServe(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	select {
+	case err = <-done:
+		assert.NilError(t, err)
+	case <-time.After(server.SHUTDOWN_TIMEOUT):
+		t.Fatal("server not shut down")
+	}
+}
+
+func post(t *testing.T, url string, body interface{}) (status int, res server.Response) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	json.NewDecoder(resp.Body).Decode(&res)
+	return resp.StatusCode, res
+}
+
+func getHomeDirectory() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(dir, "server", "", 1), nil
+}
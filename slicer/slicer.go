@@ -13,6 +13,9 @@ const (
 	// MAX_SLICES ...
 	MAX_SLICES = 4 // The owner of the data + 3 trustees is optimal as of this version
 
+	// MAX_INDEXED_SLICES is the maximum number of slices in a crumbl, its crumbs' index being written in two hexadecimal characters
+	MAX_INDEXED_SLICES = 256
+
 	// MAX_DELTA is the maximum allowed deltaMax in the system as of this version
 	MAX_DELTA = 5
 
@@ -130,10 +133,10 @@ func (s *Slicer) buildSplitMask(dataLength int, seed Seed) (masks []mask, err er
 	catchUp := dl - averageSliceLength*nos
 
 	length := 0.
-	rand.Seed(int64(seed))
+	rng := rand.New(rand.NewSource(int64(seed))) // A local source keeps the masks predictable when slicing concurrently
 	leftRound := nos
 	for dataLength > 0 {
-		randomNum := rand.Float64()*dm/2. + math.Floor(catchUp/leftRound)
+		randomNum := rng.Float64()*dm/2. + math.Floor(catchUp/leftRound)
 		addedNum := math.Min(float64(dataLength), math.Ceil(randomNum)+averageSliceLength)
 		// General rounding pb corrected at the end
 		if leftRound == 1. && length+addedNum < dl {
@@ -0,0 +1,275 @@
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Only a subset of the JSON path syntax is supported, ie. the root `$` followed by any of:
+// - `.name` or `['name']` for the field of an object;
+// - `[n]` for the item at the zero-based index n of an array;
+// - `.*` or `[*]` for any field of an object or any item of an array.
+// For example, `$.customer.email` or `$.payments[*].iban`.
+
+//--- TYPES
+
+// Path is a parsed JSON path selector
+type Path struct {
+	selector string
+	steps    []step
+}
+
+// Match is a string value selected in a JSON document
+type Match struct {
+	Path  string // The actual path of the value, eg. `$.payments[1].iban`
+	Start int    // The offset of the opening quote of the value in the document
+	End   int    // The offset right after the closing quote of the value in the document
+	Value string // The unescaped value
+}
+
+type step struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// frame is an object or an array being walked through
+type frame struct {
+	isObject  bool
+	expectKey bool
+	key       string
+	index     int
+}
+
+//--- METHODS
+
+// String returns the selector the path was parsed from
+func (p Path) String() string {
+	return p.selector
+}
+
+// matches tells whether the passed location, ie. a list of keys and indices from the root, is selected by the path
+func (p Path) matches(location []interface{}) bool {
+	if len(location) != len(p.steps) {
+		return false
+	}
+	for i, s := range p.steps {
+		switch l := location[i].(type) {
+		case string:
+			if !s.wildcard && (s.isIndex || s.name != l) {
+				return false
+			}
+		case int:
+			if !s.wildcard && (!s.isIndex || s.index != l) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//--- FUNCTIONS
+
+// Parse returns the path of the passed selector, or an error if it's malformed or not supported
+func Parse(selector string) (p Path, err error) {
+	if !strings.HasPrefix(selector, "$") {
+		err = fmt.Errorf("%w: %s must start with $", ErrInvalidPath, selector)
+		return
+	}
+	p.selector = selector
+	rest := selector[1:]
+	for rest != "" {
+		var s step
+		switch {
+		case rest == "." || rest == "[":
+			err = fmt.Errorf("%w: %s is truncated", ErrInvalidPath, selector)
+			return
+		case strings.HasPrefix(rest, ".*"):
+			s.wildcard = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				err = fmt.Errorf("%w: missing ] in %s", ErrInvalidPath, selector)
+				return
+			}
+			inside := rest[1:end]
+			switch {
+			case inside == "*":
+				s.wildcard = true
+			case len(inside) >= 2 && (inside[0] == '\'' || inside[0] == '"') && inside[len(inside)-1] == inside[0]:
+				s.name = inside[1 : len(inside)-1]
+			default:
+				index, e := strconv.Atoi(inside)
+				if e != nil || index < 0 {
+					err = fmt.Errorf("%w: invalid index %s in %s", ErrInvalidPath, inside, selector)
+					return
+				}
+				s.index = index
+				s.isIndex = true
+			}
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "."):
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			s.name = rest[1 : end+1]
+			if s.name == "" {
+				err = fmt.Errorf("%w: empty field name in %s", ErrInvalidPath, selector)
+				return
+			}
+			rest = rest[end+1:]
+		default:
+			err = fmt.Errorf("%w: unexpected %s in %s", ErrInvalidPath, rest, selector)
+			return
+		}
+		p.steps = append(p.steps, s)
+	}
+	return
+}
+
+// Find returns the string values of the passed JSON document selected by any of the paths, in the order they appear in the document.
+// Selected values that are not strings are ignored, and the document must hold a single JSON value, any truncation or trailing data being an error.
+func Find(document []byte, paths ...Path) (matches []Match, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	var stack []*frame
+	for {
+		start := int(decoder.InputOffset())
+		token, e := decoder.Token()
+		if e == io.EOF && len(stack) > 0 {
+			return nil, fmt.Errorf("%w: unexpected end of document", ErrInvalidDocument)
+		}
+		if e == io.EOF {
+			return
+		}
+		if e != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, e)
+		}
+		var parent *frame
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+		if parent != nil && parent.isObject && parent.expectKey {
+			if key, ok := token.(string); ok {
+				parent.key = key
+				parent.expectKey = false
+				continue
+			}
+		}
+		switch token {
+		case json.Delim('{'):
+			stack = append(stack, &frame{isObject: true, expectKey: true})
+			continue
+		case json.Delim('['):
+			stack = append(stack, &frame{})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		default:
+			if value, ok := token.(string); ok {
+				location := locate(stack)
+				for _, p := range paths {
+					if p.matches(location) {
+						// The token may be preceded by spaces and separators
+						end := int(decoder.InputOffset())
+						matches = append(matches, Match{
+							Path:  toSelector(location),
+							Start: start + bytes.IndexByte(document[start:end], '"'),
+							End:   end,
+							Value: value,
+						})
+						break
+					}
+				}
+			}
+		}
+
+		// A value is complete
+		if len(stack) == 0 {
+			break
+		}
+		parent = stack[len(stack)-1]
+		if parent.isObject {
+			parent.expectKey = true
+		} else {
+			parent.index++
+		}
+	}
+	if _, e := decoder.Token(); e != io.EOF {
+		return nil, fmt.Errorf("%w: unexpected data after the document", ErrInvalidDocument)
+	}
+	return
+}
+
+// Replace returns a copy of the passed document where each match is replaced by the value at the same index, as a JSON string
+func Replace(document []byte, matches []Match, values []string) ([]byte, error) {
+	if len(matches) != len(values) {
+		return nil, errors.New("not as many values as matches")
+	}
+	var replaced bytes.Buffer
+	encoder := json.NewEncoder(&replaced)
+	encoder.SetEscapeHTML(false)
+	offset := 0
+	for i, m := range matches {
+		if m.Start < offset || m.End > len(document) {
+			return nil, errors.New("unordered or out of range matches")
+		}
+		replaced.Write(document[offset:m.Start])
+		if err := encoder.Encode(values[i]); err != nil {
+			return nil, err
+		}
+		replaced.Truncate(replaced.Len() - 1) // Removes the newline added by the encoder
+		offset = m.End
+	}
+	replaced.Write(document[offset:])
+	return replaced.Bytes(), nil
+}
+
+// locate returns the keys and indices leading to the current value
+func locate(stack []*frame) []interface{} {
+	location := make([]interface{}, len(stack))
+	for i, f := range stack {
+		if f.isObject {
+			location[i] = f.key
+		} else {
+			location[i] = f.index
+		}
+	}
+	return location
+}
+
+// toSelector returns the selector of the passed location
+func toSelector(location []interface{}) string {
+	var selector strings.Builder
+	selector.WriteString("$")
+	for _, l := range location {
+		switch l := l.(type) {
+		case int:
+			selector.WriteString("[" + strconv.Itoa(l) + "]")
+		case string:
+			if l != "" && !strings.ContainsAny(l, ".[]'\"") {
+				selector.WriteString("." + l)
+			} else {
+				selector.WriteString("['" + l + "']")
+			}
+		}
+	}
+	return selector.String()
+}
+
+//--- ERRORS
+
+var (
+	// ErrInvalidPath is returned when a JSON path selector is malformed or not supported
+	ErrInvalidPath = errors.New("invalid JSON path")
+
+	// ErrInvalidDocument is returned when a JSON document is malformed
+	ErrInvalidDocument = errors.New("invalid JSON document")
+)
@@ -0,0 +1,72 @@
+package jsonpath_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cyrildever/crumbl-exe/utils/jsonpath"
+
+	"gotest.tools/assert"
+)
+
+// TestParse ...
+func TestParse(t *testing.T) {
+	for _, selector := range []string{"$", "$.customer.email", "$.payments[*].iban", "$['first name'][0].*", `$["a.b"]`} {
+		p, err := jsonpath.Parse(selector)
+		assert.NilError(t, err)
+		assert.Equal(t, p.String(), selector)
+	}
+	for _, selector := range []string{"customer.email", "$.", "$..email", "$[-1]", "$[abc]", "$.payments[*", "$email"} {
+		_, err := jsonpath.Parse(selector)
+		assert.Assert(t, errors.Is(err, jsonpath.ErrInvalidPath), selector)
+	}
+}
+
+// TestFindReplace ...
+func TestFindReplace(t *testing.T) {
+	document := []byte(`{"customer": {"email":"cdever@edgewhere.fr", "age": 42},
+  "payments": [{"iban": "FR76 0001"}, {"amount": 12}, {"iban": null}, {"iban": "DE89"}],
+  "email": "not selected"}`)
+	email, _ := jsonpath.Parse("$.customer.email")
+	iban, _ := jsonpath.Parse("$.payments[*].iban")
+	age, _ := jsonpath.Parse("$.customer.age")
+
+	matches, err := jsonpath.Find(document, email, iban, age)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(matches), 3)
+	assert.Equal(t, matches[0].Path, "$.customer.email")
+	assert.Equal(t, matches[0].Value, "cdever@edgewhere.fr")
+	assert.Equal(t, string(document[matches[0].Start:matches[0].End]), `"cdever@edgewhere.fr"`)
+	assert.Equal(t, matches[1].Path, "$.payments[0].iban")
+	assert.Equal(t, matches[1].Value, "FR76 0001")
+	assert.Equal(t, matches[2].Path, "$.payments[3].iban")
+
+	replaced, err := jsonpath.Replace(document, matches, []string{"a<b", "1", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(replaced), `{"customer": {"email":"a<b", "age": 42},
+  "payments": [{"iban": "1"}, {"amount": 12}, {"iban": null}, {"iban": ""}],
+  "email": "not selected"}`)
+
+	root, _ := jsonpath.Parse("$")
+	matches, err = jsonpath.Find([]byte(` "root" `), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, matches[0].Start, 1)
+
+	_, err = jsonpath.Find([]byte(`{"customer": }`), email)
+	assert.Assert(t, errors.Is(err, jsonpath.ErrInvalidDocument))
+	_, err = jsonpath.Find([]byte(`{"customer": {"email": "a"}`), email)
+	assert.Error(t, err, "invalid JSON document: unexpected end of document")
+	for _, trailing := range []string{` {"customer": {}}`, ` ]`, ` x`} {
+		_, err = jsonpath.Find([]byte(`{"customer": {"email": "a"}}`+trailing), email)
+		assert.Error(t, err, "invalid JSON document: unexpected data after the document")
+	}
+	matches, err = jsonpath.Find([]byte("{\"customer\": {\"email\": \"a\"}} \n"), email)
+	assert.NilError(t, err)
+	assert.Equal(t, len(matches), 1)
+}